@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleInterrupts returns two contexts derived from parent. The first
+// SIGINT or SIGTERM cancels stop, so no new captures are started while the
+// in-flight ones finish. A second signal cancels abort as well, which
+// aborts the in-flight captures. release stops listening for signals.
+func handleInterrupts(parent context.Context, out io.Writer) (stop, abort context.Context, release func()) {
+	stop, cancelStop := context.WithCancel(parent)
+	abort, cancelAbort := context.WithCancel(parent)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+			fmt.Fprintln(out, "Interrupted, waiting for in-flight captures to finish (interrupt again to abort them)")
+			cancelStop()
+		case <-done:
+			return
+		}
+
+		select {
+		case <-signals:
+			fmt.Fprintln(out, "Aborting in-flight captures")
+			cancelAbort()
+		case <-done:
+		}
+	}()
+
+	release = func() {
+		signal.Stop(signals)
+		close(done)
+		cancelStop()
+		cancelAbort()
+	}
+
+	return stop, abort, release
+}
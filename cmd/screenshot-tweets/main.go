@@ -0,0 +1,486 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"screenshot-tweets/config"
+	apperrors "screenshot-tweets/internal/errors"
+	"screenshot-tweets/markdown"
+	"screenshot-tweets/screenshot"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	markdownFile    string
+	configFile      string
+	viewportWidth   int
+	viewportHeight  int
+	outputDir       string
+	verbose         bool
+	dryRun          bool
+	retryFailed     bool
+	concurrency     int
+	captureOrder    []string
+	formats         []string
+	selector        string
+	padding         int
+	fullPage        bool
+	maxHeight       int
+	colorSchemes    []string
+	reducedMotion   bool
+	mediaType       string
+	dismissOverlays bool
+	overlayRules    string
+	blocklistFile   string
+	blockTypes      []string
+	device          string
+	scale           float64
+	originalRes     string
+	cookieFile      string
+	proxy           string
+	proxyBypass     []string
+	wait            []string
+	inject          []string
+	injectStrict    bool
+	highlightText   bool
+	pdfPage         int
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "screenshot-tweets",
+	Short: "Capture screenshots for URLs in a Day-format markdown file",
+	Long: `screenshot-tweets reads a markdown file made of "## Day N" sections, captures a
+screenshot for every "- URL:" entry that does not yet have one, generates
+social media sized variants and annotates the markdown with "Screen Shot:" lines.`,
+	SilenceUsage: true,
+	RunE:         runScreenshotAutomation,
+}
+
+func init() {
+	defaults := config.DefaultConfig()
+
+	rootCmd.Flags().StringVarP(&markdownFile, "file", "f", "", "Markdown file to process (required)")
+	rootCmd.Flags().StringVar(&configFile, "config", "", "Config file (default "+config.FileName+" next to the markdown file, then the user config dir)")
+	rootCmd.Flags().IntVar(&viewportWidth, "width", defaults.ViewportWidth, "Viewport width for screenshots")
+	rootCmd.Flags().IntVar(&viewportHeight, "height", defaults.ViewportHeight, "Viewport height for screenshots")
+	rootCmd.Flags().StringVar(&outputDir, "output-dir", "", "Directory for screenshots (default next to the markdown file)")
+	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be captured without writing any files")
+	rootCmd.Flags().BoolVar(&retryFailed, "retry-failed", false, "Only re-attempt entries annotated with a Screenshot Error")
+	rootCmd.Flags().IntVar(&concurrency, "concurrency", defaults.Concurrency, "Number of entries to capture in parallel")
+	rootCmd.Flags().StringSliceVar(&captureOrder, "capture-order", nil, "Capture strategies to try, in order (default youtube,file,browser)")
+	rootCmd.Flags().StringSliceVar(&formats, "formats", nil, "Output formats to generate: original and/or platform names (default original,twitter,linkedin)")
+	rootCmd.Flags().StringVar(&device, "device", "", "Device to emulate, e.g. iphone-15, pixel-8 or ipad")
+	rootCmd.Flags().Float64Var(&scale, "scale", 0, "Device pixel ratio to capture at, e.g. 2 (default the device's, otherwise 1)")
+	rootCmd.Flags().StringVar(&originalRes, "original-resolution", defaults.OriginalResolution, "Save the original at the captured size (native) or in CSS pixels (normalized)")
+	rootCmd.Flags().StringVar(&selector, "selector", "", "CSS selector of the element to capture instead of the viewport")
+	rootCmd.Flags().IntVar(&padding, "padding", 0, "Padding in pixels around the element captured with a selector")
+	rootCmd.Flags().BoolVar(&fullPage, "full-page", false, "Capture the whole page instead of the viewport")
+	rootCmd.Flags().IntVar(&pdfPage, "pdf-page", defaults.PDFPage, "Page to render for entries that link to a PDF, unless the URL has #page=N")
+	rootCmd.Flags().BoolVar(&highlightText, "highlight-text", false, "Highlight the text a #:~:text= fragment in the URL points at")
+	rootCmd.Flags().IntVar(&maxHeight, "max-height", defaults.MaxPageHeight, "Maximum height in pixels of full-page captures")
+	rootCmd.Flags().StringSliceVar(&colorSchemes, "color-scheme", nil, "Color schemes to capture, light and/or dark (default the browser's)")
+	rootCmd.Flags().BoolVar(&reducedMotion, "reduced-motion", false, "Emulate prefers-reduced-motion: reduce")
+	rootCmd.Flags().StringVar(&mediaType, "media", "", "Emulated CSS media type, screen or print")
+	rootCmd.Flags().StringArrayVar(&wait, "wait", nil, "Step to wait for after the page loads, repeatable: selector:CSS, js:EXPR, idle:DURATION, stable:DURATION, delay:DURATION or none (default stable:1s, delay:2s)")
+	rootCmd.Flags().StringArrayVar(&inject, "inject", nil, "CSS or JavaScript file to inject into every page before capturing, repeatable")
+	rootCmd.Flags().BoolVar(&injectStrict, "inject-strict", false, "Fail captures whose injected CSS or JavaScript fails instead of reporting it")
+	rootCmd.Flags().BoolVar(&dismissOverlays, "dismiss-overlays", defaults.DismissOverlays, "Dismiss cookie banners and other overlays before capturing")
+	rootCmd.Flags().StringVar(&overlayRules, "overlay-rules", "", "YAML file with additional overlay rules")
+	rootCmd.Flags().StringVar(&blocklistFile, "blocklist", "", "Hosts or Adblock style file of requests to block")
+	rootCmd.Flags().StringSliceVar(&blockTypes, "block-types", nil, "Resource types to block, e.g. media,font,websocket")
+	rootCmd.Flags().StringVar(&cookieFile, "cookies", "", "Netscape cookies.txt or JSON cookie file to send to matching domains")
+	rootCmd.Flags().StringVar(&proxy, "proxy", "", "Proxy URL for the browser and downloads, e.g. http://proxy:3128 or socks5://proxy:1080")
+	rootCmd.Flags().StringSliceVar(&proxyBypass, "proxy-bypass", nil, "Hosts to reach without the proxy, e.g. localhost,*.internal")
+	rootCmd.MarkFlagRequired("file")
+}
+
+func main() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func runScreenshotAutomation(cmd *cobra.Command, args []string) error {
+	out := cmd.OutOrStdout()
+
+	mf, err := markdown.ParseMarkdownFile(markdownFile)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(cmd, mf)
+	if err != nil {
+		return err
+	}
+
+	shotConfig := cfg.ScreenshotConfig(filepath.Dir(markdownFile))
+
+	platforms, err := cfg.ResolvePlatforms()
+	if err != nil {
+		return err
+	}
+
+	devices, err := cfg.ResolveDevices()
+	if err != nil {
+		return err
+	}
+	injections := make(map[string]screenshot.Injection)
+	for _, entry := range mf.Entries {
+		if _, ok := devices[entry.Device]; entry.Device != "" && !ok {
+			return fmt.Errorf("day %d: unknown device %q", entry.Day, entry.Device)
+		}
+		for _, scheme := range entry.ColorSchemes {
+			if scheme != "light" && scheme != "dark" {
+				return fmt.Errorf("day %d: invalid color scheme %q, use light or dark", entry.Day, scheme)
+			}
+		}
+		if entry.MediaType != "" && entry.MediaType != "screen" && entry.MediaType != "print" {
+			return fmt.Errorf("day %d: invalid media type %q, use screen or print", entry.Day, entry.MediaType)
+		}
+		if _, err := screenshot.ParseWaitSteps(entry.Wait); err != nil {
+			return fmt.Errorf("day %d: %w", entry.Day, err)
+		}
+		if err := loadEntryInjections(injections, entry); err != nil {
+			return fmt.Errorf("day %d: %w", entry.Day, err)
+		}
+	}
+
+	if shotConfig.OverlayRules, err = cfg.OverlayRules(); err != nil {
+		return err
+	}
+
+	if shotConfig.Inject, err = cfg.Injections(); err != nil {
+		return err
+	}
+
+	if shotConfig.Blocklist, err = cfg.Blocklist(); err != nil {
+		return err
+	}
+
+	if shotConfig.Proxy, err = cfg.ResolveProxy(); err != nil {
+		return err
+	}
+
+	if shotConfig.Sites, err = cfg.SiteRules(); err != nil {
+		return err
+	}
+
+	if shotConfig.Cookies, err = cfg.Cookies(); err != nil {
+		return err
+	}
+
+	retryConfig := apperrors.NewDefaultRetryConfig()
+	retryConfig.MaxRetries = cfg.MaxRetries
+
+	entries := mf.GetEntriesWithoutScreenshots()
+	if retryFailed {
+		entries = mf.GetEntriesWithErrors()
+	}
+	logVerbose(out, "Parsed %d entries from %s, %d need screenshots\n", len(mf.Entries), markdownFile, len(entries))
+	logVerbose(out, "Viewport %dx%d at %gx, timeout %s, output dir %s, max retries %d\n",
+		shotConfig.ViewportWidth, shotConfig.ViewportHeight, shotConfig.PixelRatio(), shotConfig.Timeout, shotConfig.OutputDir, retryConfig.MaxRetries)
+
+	if len(entries) == 0 {
+		fmt.Fprintln(out, "No entries need screenshots")
+		return nil
+	}
+
+	session := screenshot.NewSession(shotConfig)
+	defer session.Close()
+
+	if len(cfg.CaptureOrder) > 0 {
+		if err := session.Registry().SetOrder(cfg.CaptureOrder...); err != nil {
+			return cfg.FieldError("capture_order", err)
+		}
+	}
+	logVerbose(out, "Capture order: %s\n", strings.Join(session.Registry().Order(), ", "))
+	for _, entry := range entries {
+		if err := session.Registry().ValidateOrder(entry.CaptureOrder...); err != nil {
+			return fmt.Errorf("day %d: %w", entry.Day, err)
+		}
+	}
+	logVerbose(out, "Output formats: %s\n", strings.Join(outputFormats(cfg), ", "))
+	if cfg.DismissOverlays {
+		logVerbose(out, "Dismissing overlays with %d rules\n", len(shotConfig.OverlayRules))
+	}
+	if len(shotConfig.Inject) > 0 {
+		logVerbose(out, "Injecting %d CSS and JavaScript snippets\n", len(shotConfig.Inject))
+	}
+	if shotConfig.Blocklist != nil {
+		logVerbose(out, "Blocking requests with %d rules\n", shotConfig.Blocklist.Len())
+	}
+	if shotConfig.Proxy != nil {
+		logVerbose(out, "Using proxy %s\n", shotConfig.Proxy)
+	}
+	if len(shotConfig.Sites) > 0 {
+		logVerbose(out, "Adding headers or basic auth for %d sites\n", len(shotConfig.Sites))
+	}
+	if shotConfig.Cookies != nil {
+		logVerbose(out, "Loaded %d cookies from %s\n", shotConfig.Cookies.Len(), cfg.CookieFile)
+	}
+
+	p := newPipeline(out, mf, shotConfig.OutputDir, cfg.Concurrency)
+	p.config = shotConfig
+	p.colorSchemes = cfg.ColorSchemes
+	p.platforms = platforms
+	p.devices = devices
+	p.injections = injections
+	p.normalize = cfg.OriginalResolution == config.NormalizedResolution
+	if !cfg.KeepOriginal() {
+		p.primary = cfg.Formats()[0]
+	}
+
+	if dryRun {
+		captures := 0
+		for _, entry := range entries {
+			variants := p.entryVariants(entry)
+			captures += len(variants)
+			for _, variant := range variants {
+				fmt.Fprintf(out, "[dry-run] Day %d: would capture %s -> %s\n",
+					entry.Day, entry.URL, filepath.Join(shotConfig.OutputDir, variant.filename))
+				logDryRunConfig(out, entry, variant.config)
+			}
+			switch names := session.Registry().Candidates(entry.URL, variants[0].config.CaptureOrder...); len(names) {
+			case 0:
+				logVerbose(out, "[dry-run] Day %d: no capturer can handle this URL\n", entry.Day)
+			case 1:
+				logVerbose(out, "[dry-run] Day %d: using %s capturer\n", entry.Day, names[0])
+			default:
+				logVerbose(out, "[dry-run] Day %d: trying %s capturers\n", entry.Day, strings.Join(names, ", "))
+			}
+			if entry.Error != "" {
+				logVerbose(out, "[dry-run] Day %d: previous failure: %s\n", entry.Day, entry.Error)
+			}
+		}
+		fmt.Fprintf(out, "[dry-run] %d screenshots would be captured\n", captures)
+		return nil
+	}
+
+	if err := os.MkdirAll(shotConfig.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	p.capture = p.sessionCapture(session, retryConfig)
+
+	stop, abort, release := handleInterrupts(cmd.Context(), out)
+	defer release()
+
+	logVerbose(out, "Capturing with concurrency %d\n", cfg.Concurrency)
+	summary, err := p.run(stop, abort, entries)
+	if err != nil {
+		return err
+	}
+
+	if summary.captured+summary.failed > 0 {
+		if err := mf.WriteMarkdownFile(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(out, "Captured %d screenshots, %d failed\n", summary.captured, summary.failed)
+	if shotConfig.Blocklist != nil {
+		fmt.Fprintf(out, "Blocked %d requests\n", summary.blocked)
+	}
+
+	if summary.skipped > 0 {
+		return fmt.Errorf("interrupted, %d of %d entries were not captured", summary.skipped, len(entries))
+	}
+
+	if summary.failed > 0 {
+		return fmt.Errorf("%d of %d screenshots failed", summary.failed, len(entries))
+	}
+
+	return nil
+}
+
+// loadConfig resolves the configuration with flags taking precedence over
+// the markdown file, the environment, the config file and the defaults, in
+// that order.
+func loadConfig(cmd *cobra.Command, mf *markdown.MarkdownFile) (*config.Config, error) {
+	path := configFile
+	if path == "" {
+		path = config.FindConfigFile(filepath.Dir(markdownFile))
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		logVerbose(cmd.OutOrStdout(), "Using config file %s\n", path)
+	}
+
+	if len(mf.Formats) > 0 {
+		cfg.OutputFormats = mf.Formats
+		cfg.SetSource("output_formats", "markdown file "+mf.FilePath)
+	}
+
+	for _, f := range []struct {
+		flag  string
+		field string
+		apply func()
+	}{
+		{"width", "viewport_width", func() { cfg.ViewportWidth = viewportWidth }},
+		{"height", "viewport_height", func() { cfg.ViewportHeight = viewportHeight }},
+		{"output-dir", "output_dir", func() { cfg.OutputDir = outputDir }},
+		{"concurrency", "concurrency", func() { cfg.Concurrency = concurrency }},
+		{"capture-order", "capture_order", func() { cfg.CaptureOrder = captureOrder }},
+		{"formats", "output_formats", func() { cfg.OutputFormats = formats }},
+		{"device", "device", func() { cfg.Device = device }},
+		{"scale", "scale", func() { cfg.Scale = scale }},
+		{"original-resolution", "original_resolution", func() { cfg.OriginalResolution = originalRes }},
+		{"selector", "selector", func() { cfg.Selector = selector }},
+		{"padding", "selector_padding", func() { cfg.SelectorPadding = padding }},
+		{"full-page", "full_page", func() { cfg.FullPage = fullPage }},
+		{"pdf-page", "pdf_page", func() { cfg.PDFPage = pdfPage }},
+		{"highlight-text", "highlight_text", func() { cfg.HighlightText = highlightText }},
+		{"max-height", "max_page_height", func() { cfg.MaxPageHeight = maxHeight }},
+		{"color-scheme", "color_schemes", func() { cfg.ColorSchemes = colorSchemes }},
+		{"reduced-motion", "reduced_motion", func() { cfg.ReducedMotion = reducedMotion }},
+		{"media", "media_type", func() { cfg.MediaType = mediaType }},
+		{"wait", "wait", func() { cfg.Wait = wait }},
+		{"inject", "inject", func() { cfg.Inject = injectFiles(inject) }},
+		{"inject-strict", "inject_strict", func() { cfg.InjectStrict = injectStrict }},
+		{"dismiss-overlays", "dismiss_overlays", func() { cfg.DismissOverlays = dismissOverlays }},
+		{"overlay-rules", "overlay_rules_file", func() { cfg.OverlayRulesFile = overlayRules }},
+		{"blocklist", "blocklist_file", func() { cfg.BlocklistFile = blocklistFile }},
+		{"block-types", "block_resource_types", func() { cfg.BlockResourceTypes = blockTypes }},
+		{"cookies", "cookie_file", func() { cfg.CookieFile = cookieFile }},
+		{"proxy", "proxy", func() { cfg.Proxy = proxy }},
+		{"proxy-bypass", "proxy_bypass", func() { cfg.ProxyBypass = proxyBypass }},
+	} {
+		if cmd.Flags().Changed(f.flag) {
+			f.apply()
+			cfg.SetSource(f.field, "flag --"+f.flag)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func injectFiles(files []string) []config.Injection {
+	injections := make([]config.Injection, len(files))
+	for i, file := range files {
+		injections[i] = config.Injection{File: file}
+	}
+	return injections
+}
+
+// loadEntryInjections loads the Inject files of entry, relative to the
+// markdown file, into injections unless already loaded.
+func loadEntryInjections(injections map[string]screenshot.Injection, entry markdown.DayEntry) error {
+	for _, ref := range entry.Inject {
+		if _, ok := injections[ref]; ok {
+			continue
+		}
+
+		path := ref
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(markdownFile), path)
+		}
+		injection, err := screenshot.LoadInjection(path)
+		if err != nil {
+			return err
+		}
+		if err := injection.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", injection, err)
+		}
+		injections[ref] = injection
+	}
+	return nil
+}
+
+// logDryRunConfig describes in verbose mode how an entry would be captured.
+func logDryRunConfig(out io.Writer, entry markdown.DayEntry, config screenshot.ScreenshotConfig) {
+	if config.Device != "" {
+		logVerbose(out, "[dry-run] Day %d: emulating %s, %dx%d at %gx\n",
+			entry.Day, config.Device, config.ViewportWidth, config.ViewportHeight, config.PixelRatio())
+	}
+
+	if config.Selector != "" {
+		logVerbose(out, "[dry-run] Day %d: capturing element %q\n", entry.Day, config.Selector)
+	} else if config.FullPage {
+		logVerbose(out, "[dry-run] Day %d: capturing the full page, up to %dpx\n", entry.Day, config.MaxHeight)
+	}
+
+	if config.Wait != nil {
+		steps := make([]string, len(config.Wait))
+		for i, step := range config.Wait {
+			steps[i] = step.String()
+		}
+		if len(steps) == 0 {
+			steps = []string{"the page load only"}
+		}
+		logVerbose(out, "[dry-run] Day %d: waiting for %s\n", entry.Day, strings.Join(steps, ", "))
+	}
+
+	if fragment := screenshot.ParseFragment(entry.URL); !fragment.IsEmpty() {
+		scroll := config.Selector == "" && !config.FullPage
+		highlight := config.HighlightText && len(fragment.Texts) > 0
+		switch {
+		case scroll && highlight:
+			logVerbose(out, "[dry-run] Day %d: scrolling to and highlighting %s\n", entry.Day, fragment)
+		case scroll:
+			logVerbose(out, "[dry-run] Day %d: scrolling to %s\n", entry.Day, fragment)
+		case highlight:
+			logVerbose(out, "[dry-run] Day %d: highlighting %s\n", entry.Day, fragment)
+		}
+	}
+
+	if injections := screenshot.MatchInjections(config.Inject, entry.URL); len(injections) > 0 {
+		sources := make([]string, len(injections))
+		for i, injection := range injections {
+			sources[i] = injection.Source
+		}
+		logVerbose(out, "[dry-run] Day %d: injecting %s\n", entry.Day, strings.Join(sources, ", "))
+	}
+
+	if sites := config.Sites.Match(entry.URL); len(sites) > 0 {
+		logVerbose(out, "[dry-run] Day %d: adding headers or basic auth for %v\n", entry.Day, sites)
+	}
+
+	if config.Cookies != nil {
+		if cookies := config.Cookies.ForURL(entry.URL); len(cookies) > 0 {
+			logVerbose(out, "[dry-run] Day %d: sending %d cookies\n", entry.Day, len(cookies))
+		}
+	}
+
+	var media []string
+	if config.ColorScheme != "" {
+		media = append(media, config.ColorScheme+" color scheme")
+	}
+	if config.ReducedMotion {
+		media = append(media, "reduced motion")
+	}
+	if config.MediaType != "" {
+		media = append(media, config.MediaType+" media")
+	}
+	if len(media) > 0 {
+		logVerbose(out, "[dry-run] Day %d: emulating %s\n", entry.Day, strings.Join(media, ", "))
+	}
+}
+
+// outputFormats lists the formats that will be written, in order.
+func outputFormats(cfg *config.Config) []string {
+	var names []string
+	if cfg.KeepOriginal() {
+		names = append(names, config.OriginalFormat)
+	}
+	return append(names, cfg.Formats()...)
+}
+
+func logVerbose(out io.Writer, format string, args ...any) {
+	if verbose {
+		fmt.Fprintf(out, format, args...)
+	}
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func executeRoot(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	rootCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		// Setting a slice flag that was set before appends to it
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			_ = slice.Replace(nil)
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+func TestDryRunDoesNotModifyFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	input, err := os.ReadFile(filepath.Join("..", "..", "testdata", "sample-input.md"))
+	require.NoError(t, err)
+
+	testFile := filepath.Join(tempDir, "tweets.md")
+	require.NoError(t, os.WriteFile(testFile, input, 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose")
+	require.NoError(t, err)
+
+	assert.Contains(t, output, "Day 1: would capture https://go.dev/blog/go1.21")
+	assert.Contains(t, output, "Day 6: would capture https://docs.docker.com/develop/dev-best-practices/")
+	assert.NotContains(t, output, "Day 4: would capture")
+	assert.NotContains(t, output, "Day 5: would capture")
+	assert.Contains(t, output, "4 screenshots would be captured")
+	assert.Contains(t, output, "Day 1: using browser capturer")
+	assert.NotContains(t, output, "Dismissing overlays")
+	assert.NotContains(t, output, "Blocking requests")
+
+	after, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, string(input), string(after))
+
+	files, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestDryRunRetryFailed(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://example.com/1
+Screenshot Error: timeout (2026-10-16T10:00Z)
+
+## Day 2
+- URL: https://example.com/2
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose")
+	require.NoError(t, err)
+	assert.NotContains(t, output, "Day 1: would capture")
+	assert.Contains(t, output, "Day 2: would capture")
+
+	output, err = executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--retry-failed")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Day 1: would capture https://example.com/1")
+	assert.Contains(t, output, "Day 1: previous failure: timeout (2026-10-16T10:00Z)")
+	assert.NotContains(t, output, "Day 2: would capture")
+}
+
+func TestDryRunConfigFilePrecedence(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	require.NoError(t, os.WriteFile(testFile, []byte("## Day 1\n- URL: https://example.com/1\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ".screenshot-tweets.yaml"), []byte(`
+viewport_width: 1024
+viewport_height: 768
+output_dir: images
+`), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--height", "700")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Using config file "+filepath.Join(tempDir, ".screenshot-tweets.yaml"))
+	assert.Contains(t, output, "Viewport 1024x700")
+	assert.Contains(t, output, "-> "+filepath.Join(tempDir, "images", "day-1-screenshot.png"))
+}
+
+func TestDryRunFormats(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	require.NoError(t, os.WriteFile(testFile, []byte("Formats: twitter, bluesky\n\n## Day 1\n- URL: https://example.com/1\n"), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Output formats: twitter, bluesky\n")
+
+	output, err = executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--formats", "original,mastodon")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Output formats: original, mastodon\n")
+}
+
+func TestDryRunSelector(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://example.com/1
+- Selector: pre code
+
+## Day 2
+- URL: https://example.com/2
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose")
+	require.NoError(t, err)
+	assert.Contains(t, output, `Day 1: capturing element "pre code"`)
+	assert.NotContains(t, output, "Day 2: capturing element")
+
+	output, err = executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--selector", "article")
+	require.NoError(t, err)
+	assert.Contains(t, output, `Day 1: capturing element "pre code"`)
+	assert.Contains(t, output, `Day 2: capturing element "article"`)
+}
+
+func TestDryRunFullPage(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://example.com/1
+
+## Day 2
+- URL: https://example.com/2
+- Full Page: no
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--full-page", "--max-height", "5000")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Day 1: capturing the full page, up to 5000px")
+	assert.NotContains(t, output, "Day 2: capturing the full page")
+}
+
+func TestDryRunColorSchemes(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://example.com/1
+- Media: print
+
+## Day 2
+- URL: https://example.com/2
+- Color Scheme: dark
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--color-scheme", "light,dark", "--reduced-motion")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Day 1: would capture https://example.com/1 -> "+filepath.Join(tempDir, "day-1-screenshot.png"))
+	assert.Contains(t, output, "Day 1: emulating light color scheme, reduced motion, print media")
+	assert.Contains(t, output, "Day 1: would capture https://example.com/1 -> "+filepath.Join(tempDir, "day-1-screenshot-dark.png"))
+	assert.Contains(t, output, "Day 1: emulating dark color scheme, reduced motion, print media")
+	assert.Contains(t, output, "Day 2: would capture https://example.com/2 -> "+filepath.Join(tempDir, "day-2-screenshot.png"))
+	assert.NotContains(t, output, "day-2-screenshot-dark.png")
+	assert.Contains(t, output, "3 screenshots would be captured")
+
+	for _, test := range []struct {
+		directive     string
+		errorContains string
+	}{
+		{"- Color Scheme: sepia", `day 1: invalid color scheme "sepia", use light or dark`},
+		{"- Media: tv", `day 1: invalid media type "tv", use screen or print`},
+	} {
+		require.NoError(t, os.WriteFile(testFile, []byte("## Day 1\n- URL: https://example.com/1\n"+test.directive+"\n"), 0644))
+		_, err = executeRoot(t, "--file", testFile, "--dry-run")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), test.errorContains)
+	}
+}
+
+func TestDryRunDevice(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://example.com/1
+
+## Day 2
+- URL: https://example.com/2
+- Device: kiosk
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ".screenshot-tweets.yaml"), []byte(`
+devices:
+  kiosk:
+    name: Lobby kiosk
+    width: 1080
+    height: 1920
+`), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--device", "iphone-15")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Viewport 393x852 at 3x")
+	assert.Contains(t, output, "Day 1: emulating iPhone 15, 393x852 at 3x")
+	assert.Contains(t, output, "Day 2: emulating Lobby kiosk, 1080x1920 at 1x")
+
+	require.NoError(t, os.WriteFile(testFile, []byte("## Day 1\n- URL: https://example.com/1\n- Device: nokia-3310\n"), 0644))
+	_, err = executeRoot(t, "--file", testFile, "--dry-run")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `day 1: unknown device "nokia-3310"`)
+}
+
+func TestDryRunWait(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://example.com/1
+
+## Day 2
+- URL: https://example.com/2
+- Wait: selector:#app
+- Wait: js:window.appReady === true
+
+## Day 3
+- URL: https://example.com/3
+- Wait: none
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--wait", "idle:500ms", "--wait", "delay:1s")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Day 1: waiting for idle 500ms, delay 1s")
+	assert.Contains(t, output, "Day 2: waiting for selector #app, js window.appReady === true")
+	assert.Contains(t, output, "Day 3: waiting for the page load only")
+
+	require.NoError(t, os.WriteFile(testFile, []byte("## Day 1\n- URL: https://example.com/1\n- Wait: forever\n"), 0644))
+	_, err = executeRoot(t, "--file", testFile, "--dry-run")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `day 1: unknown wait step "forever"`)
+}
+
+func TestDryRunInject(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://example.com/1
+
+## Day 2
+- URL: https://example.com/2
+- Inject: inject/expand.js
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "inject"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "inject", "expand.js"), []byte("document.querySelector('details').open = true"), 0644))
+	hideHeader := filepath.Join(tempDir, "hide-header.css")
+	require.NoError(t, os.WriteFile(hideHeader, []byte("header { display: none }"), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--inject", hideHeader)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Injecting 1 CSS and JavaScript snippets")
+	assert.Contains(t, output, "Day 1: injecting "+hideHeader+"\n")
+	assert.Contains(t, output, "Day 2: injecting "+hideHeader+", "+filepath.Join(tempDir, "inject", "expand.js")+"\n")
+
+	require.NoError(t, os.WriteFile(testFile, []byte("## Day 1\n- URL: https://example.com/1\n- Inject: missing.css\n"), 0644))
+	_, err = executeRoot(t, "--file", testFile, "--dry-run")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "day 1: failed to read injection "+filepath.Join(tempDir, "missing.css"))
+}
+
+func TestDryRunFragments(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://example.com/1#section-3
+
+## Day 2
+- URL: https://example.com/2#:~:text=the%20lazy,dog
+
+## Day 3
+- URL: https://example.com/3#:~:text=quick
+- Full Page: yes
+
+## Day 4
+- URL: https://example.com/4#:~:text=quick
+- Highlight: no
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--highlight-text")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Day 1: scrolling to #section-3\n")
+	assert.Contains(t, output, `Day 2: scrolling to and highlighting text "the lazy…dog"`)
+	assert.Contains(t, output, `Day 3: highlighting text "quick"`)
+	assert.Contains(t, output, `Day 4: scrolling to text "quick"`)
+}
+
+func TestDryRunCaptureOrder(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://example.com/1
+
+## Day 2
+- URL: https://example.com/2
+- Capture: browser
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--capture-order", "youtube,opengraph,browser")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Capture order: youtube, opengraph, browser")
+	assert.Contains(t, output, "Day 1: trying opengraph, browser capturers")
+	assert.Contains(t, output, "Day 2: using browser capturer")
+
+	require.NoError(t, os.WriteFile(testFile, []byte("## Day 1\n- URL: https://example.com/1\n- Capture: opengraph, screenshot\n"), 0644))
+	_, err = executeRoot(t, "--file", testFile, "--dry-run")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `day 1: unknown capturer "screenshot"`)
+}
+
+func TestDryRunCookies(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://medium.com/@someone/a-post
+
+## Day 2
+- URL: https://example.com/2
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+	cookiesFile := filepath.Join(tempDir, "cookies.txt")
+	require.NoError(t, os.WriteFile(cookiesFile, []byte(".medium.com\tTRUE\t/\tTRUE\t0\tsid\tsecret-session-value\n"), 0600))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose", "--cookies", cookiesFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Loaded 1 cookies from "+cookiesFile)
+	assert.Contains(t, output, "Day 1: sending 1 cookies")
+	assert.NotContains(t, output, "Day 2: sending")
+	assert.NotContains(t, output, "secret-session-value")
+}
+
+func TestDryRunSites(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("STAGING_PASSWORD", "password-value")
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := `## Day 1
+- URL: https://staging.example.com/preview
+
+## Day 2
+- URL: https://example.org/
+`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, ".screenshot-tweets.yaml"), []byte(`
+sites:
+  "*.example.com":
+    username: deploy
+    password_env: STAGING_PASSWORD
+`), 0644))
+
+	output, err := executeRoot(t, "--file", testFile, "--dry-run", "--verbose")
+	require.NoError(t, err)
+	assert.Contains(t, output, "Adding headers or basic auth for 1 sites")
+	assert.Contains(t, output, "Day 1: adding headers or basic auth for [*.example.com]")
+	assert.NotContains(t, output, "Day 2: adding headers")
+	assert.NotContains(t, output, "password-value")
+}
+
+func TestRunNothingToCapture(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	require.NoError(t, os.WriteFile(testFile, []byte("## Day 1\nNo URL here.\n"), 0644))
+
+	output, err := executeRoot(t, "--file", testFile)
+	require.NoError(t, err)
+	assert.Contains(t, output, "No entries need screenshots")
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		args          []string
+		errorContains string
+	}{
+		{
+			name:          "missing file flag",
+			args:          []string{"--dry-run"},
+			errorContains: `required flag(s) "file" not set`,
+		},
+		{
+			name:          "nonexistent file",
+			args:          []string{"--file", "/non/existent/file.md"},
+			errorContains: "failed to open file",
+		},
+		{
+			name:          "unknown capturer",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--dry-run", "--capture-order", "browser,pdf"},
+			errorContains: `unknown capturer "pdf"`,
+		},
+		{
+			name:          "invalid viewport",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--width", "0"},
+			errorContains: "viewport width must be positive, got 0 (from flag --width)",
+		},
+		{
+			name:          "unknown format",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--formats", "original,myspace"},
+			errorContains: `unknown output format "myspace" (from flag --formats)`,
+		},
+		{
+			name:          "negative padding",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--padding", "-4"},
+			errorContains: "selector padding cannot be negative, got -4 (from flag --padding)",
+		},
+		{
+			name:          "invalid max height",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--max-height", "0"},
+			errorContains: "max page height must be positive, got 0 (from flag --max-height)",
+		},
+		{
+			name:          "invalid color scheme",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--color-scheme", "sepia"},
+			errorContains: `invalid color scheme "sepia", use light or dark (from flag --color-scheme)`,
+		},
+		{
+			name:          "missing overlay rules",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--dismiss-overlays", "--overlay-rules", "/non/existent/rules.yaml"},
+			errorContains: "failed to read overlay rules /non/existent/rules.yaml",
+		},
+		{
+			name:          "unknown device",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--device", "nokia-3310"},
+			errorContains: `unknown device "nokia-3310" (from flag --device)`,
+		},
+		{
+			name:          "invalid wait step",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--wait", "idle:0s"},
+			errorContains: `wait step "idle:0s" needs a positive duration, e.g. idle:2s (from flag --wait)`,
+		},
+		{
+			name:          "missing injection",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--inject", "/non/existent/inject.css"},
+			errorContains: "failed to read injection /non/existent/inject.css",
+		},
+		{
+			name:          "invalid PDF page",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--pdf-page", "0"},
+			errorContains: "PDF page must be at least 1, got 0 (from flag --pdf-page)",
+		},
+		{
+			name:          "invalid scale",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--scale", "-2"},
+			errorContains: "scale must be between 0 and 4, got -2 (from flag --scale)",
+		},
+		{
+			name:          "missing cookie file",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--cookies", "/non/existent/cookies.txt"},
+			errorContains: "failed to read cookie file /non/existent/cookies.txt",
+		},
+		{
+			name:          "invalid proxy",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--proxy", "ftp://proxy:21"},
+			errorContains: `unsupported proxy scheme "ftp", use http, https or socks5 (from flag --proxy)`,
+		},
+		{
+			name:          "unknown block type",
+			args:          []string{"--file", "../../testdata/sample-input.md", "--block-types", "media,videos"},
+			errorContains: `unknown resource type "videos" (from flag --block-types)`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := executeRoot(t, test.args...)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errorContains)
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apperrors "screenshot-tweets/internal/errors"
+	"screenshot-tweets/markdown"
+	"screenshot-tweets/screenshot"
+)
+
+// captureFunc writes the screenshot for entry to filename inside the output
+// directory, capturing with config.
+type captureFunc func(ctx context.Context, entry markdown.DayEntry, filename string, config screenshot.ScreenshotConfig) error
+
+// pipeline captures entries on a bounded pool of workers. Captures and
+// resizes run concurrently, while every MarkdownFile update is applied
+// afterwards in file order so the output does not depend on which capture
+// finishes first.
+type pipeline struct {
+	out         io.Writer
+	mf          *markdown.MarkdownFile
+	outputDir   string
+	concurrency int
+	config      screenshot.ScreenshotConfig
+	platforms   map[string]screenshot.SocialMediaPlatform
+	devices     map[string]screenshot.Device
+	injections  map[string]screenshot.Injection
+	capture     captureFunc
+
+	// colorSchemes are captured for every entry that does not list its own,
+	// the first one under the usual filename
+	colorSchemes []string
+
+	// primary is the platform whose variant replaces the original screenshot
+	// when the original is not kept, empty to keep the original
+	primary string
+
+	// normalize scales browser screenshots captured at a higher pixel ratio
+	// back to CSS pixels once their variants are generated
+	normalize bool
+
+	blocked atomic.Int64
+	mu      sync.Mutex
+}
+
+type entryResult struct {
+	entry    markdown.DayEntry
+	filename string
+	err      error
+	skipped  bool
+}
+
+type runSummary struct {
+	captured int
+	failed   int
+	skipped  int
+	blocked  int
+}
+
+func newPipeline(out io.Writer, mf *markdown.MarkdownFile, outputDir string, concurrency int) *pipeline {
+	config := screenshot.NewDefaultConfig()
+	config.OutputDir = outputDir
+
+	return &pipeline{
+		out:         out,
+		mf:          mf,
+		outputDir:   outputDir,
+		concurrency: concurrency,
+		config:      config,
+		platforms:   screenshot.PlatformConfigs,
+	}
+}
+
+func (p *pipeline) sessionCapture(session *screenshot.Session, retryConfig apperrors.RetryConfig) captureFunc {
+	return func(ctx context.Context, entry markdown.DayEntry, filename string, config screenshot.ScreenshotConfig) error {
+		rc := retryConfig
+		rc.OnRetry = func(attempt int, err error, delay time.Duration) {
+			p.logVerbose("Day %d: attempt %d failed (%v), retrying in %s\n", entry.Day, attempt+1, err, delay.Round(time.Millisecond))
+		}
+		config.OnOverlay = func(action screenshot.OverlayAction) {
+			p.logVerbose("Day %d: overlay rule %s fired (%s %s)\n", entry.Day, action.Rule, action.Action, action.Selector)
+		}
+
+		config.OnInjectError = func(source string, err error) {
+			p.logVerbose("Day %d: injection %s failed: %v\n", entry.Day, source, err)
+		}
+
+		config.OnWarning = func(err error) {
+			p.logVerbose("Day %d: warning: %v\n", entry.Day, err)
+		}
+
+		var blocked atomic.Int64
+		config.OnBlocked = func(url string) {
+			blocked.Add(1)
+			p.blocked.Add(1)
+		}
+
+		err := session.CaptureWithRetry(ctx, entry.URL, filename, entry.Day, config, rc)
+		if n := blocked.Load(); n > 0 {
+			p.logVerbose("Day %d: blocked %d requests\n", entry.Day, n)
+		}
+		return err
+	}
+}
+
+// entryConfig applies the per-entry directives of entry to base.
+func entryConfig(base screenshot.ScreenshotConfig, entry markdown.DayEntry) screenshot.ScreenshotConfig {
+	config := base
+	if entry.Selector != "" {
+		config.Selector = entry.Selector
+	}
+	if entry.FullPage != nil {
+		config.FullPage = *entry.FullPage
+	}
+	if len(entry.CaptureOrder) > 0 {
+		config.CaptureOrder = entry.CaptureOrder
+	}
+	if entry.Highlight != nil {
+		config.HighlightText = *entry.Highlight
+	}
+	if entry.ReducedMotion != nil {
+		config.ReducedMotion = *entry.ReducedMotion
+	}
+	if entry.MediaType != "" {
+		config.MediaType = entry.MediaType
+	}
+	// Wait lines are validated up front
+	if steps, err := screenshot.ParseWaitSteps(entry.Wait); len(entry.Wait) > 0 && err == nil {
+		config.Wait = steps
+	}
+	return config
+}
+
+// captureVariant is one capture made for an entry.
+type captureVariant struct {
+	filename string
+	config   screenshot.ScreenshotConfig
+}
+
+// entryVariants returns the captures to make for entry, one per color
+// scheme. The first one uses the usual filename.
+func (p *pipeline) entryVariants(entry markdown.DayEntry) []captureVariant {
+	config := entryConfig(p.config, entry)
+	if device, ok := p.devices[entry.Device]; ok {
+		config = device.Apply(config)
+	}
+	for _, ref := range entry.Inject {
+		// Entry injections run after the configured ones
+		config.Inject = append(slices.Clip(config.Inject), p.injections[ref])
+	}
+
+	schemes := p.colorSchemes
+	if len(entry.ColorSchemes) > 0 {
+		schemes = entry.ColorSchemes
+	}
+	if len(schemes) == 0 {
+		return []captureVariant{{filename: screenshot.GenerateBaseFilename(entry.Day), config: config}}
+	}
+
+	variants := make([]captureVariant, len(schemes))
+	for i, scheme := range schemes {
+		variants[i] = captureVariant{filename: screenshot.GenerateVariantFilename(entry.Day, scheme), config: config}
+		variants[i].config.ColorScheme = scheme
+	}
+	variants[0].filename = screenshot.GenerateBaseFilename(entry.Day)
+	return variants
+}
+
+// run captures entries and applies the results to the markdown file. Once
+// stop is done no further captures are started; abort is passed to the
+// captures themselves. Entries that were never started or were aborted are
+// reported as skipped and left untouched in the markdown.
+func (p *pipeline) run(stop, abort context.Context, entries []markdown.DayEntry) (runSummary, error) {
+	var summary runSummary
+
+	for _, result := range p.captureAll(stop, abort, entries) {
+		if result.skipped {
+			p.logVerbose("Day %d: skipped\n", result.entry.Day)
+			summary.skipped++
+			continue
+		}
+
+		if result.err != nil {
+			fmt.Fprintf(p.out, "Day %d: failed: %v\n", result.entry.Day, result.err)
+			if err := p.mf.SetScreenshotError(result.entry.Day, classifyError(result.entry, result.err).Annotation()); err != nil {
+				return summary, err
+			}
+			summary.failed++
+			continue
+		}
+
+		if err := p.mf.ClearScreenshotError(result.entry.Day); err != nil {
+			return summary, err
+		}
+
+		if err := p.mf.UpdateScreenshotReference(result.entry.Day, p.reference(result.filename)); err != nil {
+			return summary, err
+		}
+
+		fmt.Fprintf(p.out, "Day %d: saved %s\n", result.entry.Day, filepath.Join(p.outputDir, result.filename))
+		summary.captured++
+	}
+
+	summary.blocked = int(p.blocked.Load())
+	return summary, nil
+}
+
+func (p *pipeline) captureAll(stop, abort context.Context, entries []markdown.DayEntry) []entryResult {
+	results := make([]entryResult, len(entries))
+	for i, entry := range entries {
+		results[i] = entryResult{entry: entry, skipped: true}
+	}
+
+	jobs := make(chan int)
+
+	workers := p.concurrency
+	if workers > len(entries) {
+		workers = len(entries)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = p.captureEntry(abort, entries[i])
+			}
+		}()
+	}
+
+dispatch:
+	for i := range entries {
+		if stop.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- i:
+		case <-stop.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// captureEntry makes every capture for entry. The result refers to the file
+// of the first one.
+func (p *pipeline) captureEntry(ctx context.Context, entry markdown.DayEntry) entryResult {
+	result := entryResult{entry: entry}
+
+	for i, variant := range p.entryVariants(entry) {
+		if variant.config.ColorScheme != "" {
+			p.logVerbose("Day %d: capturing %s (%s)\n", entry.Day, entry.URL, variant.config.ColorScheme)
+		} else {
+			p.logVerbose("Day %d: capturing %s\n", entry.Day, entry.URL)
+		}
+
+		filename, err := p.captureVariant(ctx, entry, variant)
+		if err != nil {
+			result.err = err
+			// A capture cut short by an abort is not a real failure
+			result.skipped = ctx.Err() != nil
+			return result
+		}
+		if i == 0 {
+			result.filename = filename
+		}
+	}
+
+	return result
+}
+
+// captureVariant captures variant and generates its social media variants,
+// returning the file to refer to.
+func (p *pipeline) captureVariant(ctx context.Context, entry markdown.DayEntry, variant captureVariant) (string, error) {
+	var capturer string
+	config := variant.config
+	config.OnCaptured = func(name string) { capturer = name }
+
+	if err := p.capture(ctx, entry, variant.filename, config); err != nil {
+		return "", err
+	}
+	if capturer != "" {
+		p.logVerbose("Day %d: captured with %s\n", entry.Day, capturer)
+	}
+
+	p.logVerbose("Day %d: generating social media variants\n", entry.Day)
+	original := filepath.Join(p.outputDir, variant.filename)
+	if err := screenshot.ResizeForSocialMedia(original, variant.filename, p.platforms); err != nil {
+		return "", err
+	}
+
+	if p.primary == "" {
+		// Only the browser captures at the configured pixel ratio
+		if p.normalize && capturer == "browser" {
+			if err := screenshot.NormalizeResolution(original, config.PixelRatio()); err != nil {
+				return "", err
+			}
+		}
+		return variant.filename, nil
+	}
+
+	if err := os.Remove(original); err != nil {
+		return "", fmt.Errorf("failed to remove original screenshot: %w", err)
+	}
+	return p.platforms[p.primary].Filename(p.primary, strings.TrimSuffix(variant.filename, filepath.Ext(variant.filename))), nil
+}
+
+// reference returns the path written to the markdown for filename, relative
+// to the markdown file when possible.
+func (p *pipeline) reference(filename string) string {
+	path, err := filepath.Abs(filepath.Join(p.outputDir, filename))
+	if err != nil {
+		return filename
+	}
+
+	markdownDir, err := filepath.Abs(filepath.Dir(p.mf.FilePath))
+	if err != nil {
+		return path
+	}
+
+	if rel, err := filepath.Rel(markdownDir, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func (p *pipeline) logVerbose(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	logVerbose(p.out, format, args...)
+}
+
+func classifyError(entry markdown.DayEntry, err error) *apperrors.ScreenshotError {
+	var screenshotErr *apperrors.ScreenshotError
+	if errors.As(err, &screenshotErr) {
+		return screenshotErr
+	}
+	return apperrors.NewScreenshotError(entry.URL, entry.Day, err)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"screenshot-tweets/markdown"
+	"screenshot-tweets/screenshot"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pipelineInput = `# Daily Tweet Log
+
+## Day 1
+- URL: https://example.com/1
+
+## Day 2
+- URL: https://example.com/fail
+
+## Day 3
+- URL: https://example.com/3
+
+## Day 4
+- URL: https://example.com/4
+
+## Day 5
+- URL: https://example.com/5
+`
+
+var (
+	screenshotErrorDate = regexp.MustCompile(`\(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}Z\)`)
+	savedPath           = regexp.MustCompile(`saved \S*/`)
+)
+
+func fakeCapture(outputDir string, inFlight, maxInFlight *int32) captureFunc {
+	return func(ctx context.Context, entry markdown.DayEntry, filename string, config screenshot.ScreenshotConfig) error {
+		current := atomic.AddInt32(inFlight, 1)
+		defer atomic.AddInt32(inFlight, -1)
+		for {
+			seen := atomic.LoadInt32(maxInFlight)
+			if current <= seen || atomic.CompareAndSwapInt32(maxInFlight, seen, current) {
+				break
+			}
+		}
+
+		time.Sleep(time.Duration(rand.Intn(20)) * time.Millisecond)
+
+		if entry.URL == "https://example.com/fail" {
+			return fmt.Errorf("connection timeout")
+		}
+		return imaging.Save(image.NewRGBA(image.Rect(0, 0, 80, 60)), filepath.Join(outputDir, filename))
+	}
+}
+
+func runPipeline(t *testing.T, concurrency int) (string, string, int32) {
+	t.Helper()
+
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	require.NoError(t, os.WriteFile(testFile, []byte(pipelineInput), 0644))
+
+	mf, err := markdown.ParseMarkdownFile(testFile)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	var inFlight, maxInFlight int32
+	p := newPipeline(&out, mf, tempDir, concurrency)
+	p.capture = fakeCapture(tempDir, &inFlight, &maxInFlight)
+
+	summary, err := p.run(context.Background(), context.Background(), mf.GetEntriesWithoutScreenshots())
+	require.NoError(t, err)
+	assert.Equal(t, runSummary{captured: 4, failed: 1}, summary)
+	require.NoError(t, mf.WriteMarkdownFile())
+
+	content, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	return string(content), out.String(), maxInFlight
+}
+
+func TestPipelineConcurrentOutputIsDeterministic(t *testing.T) {
+	sequential, sequentialOut, sequentialMax := runPipeline(t, 1)
+	assert.Equal(t, int32(1), sequentialMax)
+
+	for i := 0; i < 3; i++ {
+		concurrent, concurrentOut, _ := runPipeline(t, 4)
+		assert.Equal(t, normalizeDates(sequential), normalizeDates(concurrent))
+		assert.Equal(t, stripDirs(sequentialOut), stripDirs(concurrentOut))
+	}
+
+	for day, annotation := range map[int]string{
+		1: "Screen Shot: day-1-screenshot.png",
+		2: "Screenshot Error: timeout (",
+		3: "Screen Shot: day-3-screenshot.png",
+		5: "Screen Shot: day-5-screenshot.png",
+	} {
+		index := strings.Index(sequential, annotation)
+		require.NotEqual(t, -1, index, annotation)
+		assert.Greater(t, index, strings.Index(sequential, fmt.Sprintf("## Day %d", day)))
+		if next := strings.Index(sequential, fmt.Sprintf("## Day %d", day+1)); next != -1 {
+			assert.Less(t, index, next)
+		}
+	}
+}
+
+func TestPipelineRespectsConcurrencyLimit(t *testing.T) {
+	_, _, maxInFlight := runPipeline(t, 2)
+	assert.LessOrEqual(t, maxInFlight, int32(2))
+}
+
+func TestPipelineReplacesOriginalWithPrimaryFormat(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	require.NoError(t, os.WriteFile(testFile, []byte("## Day 1\n- URL: https://example.com/1\n"), 0644))
+
+	mf, err := markdown.ParseMarkdownFile(testFile)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	var inFlight, maxInFlight int32
+	p := newPipeline(&out, mf, tempDir, 1)
+	p.platforms = map[string]screenshot.SocialMediaPlatform{
+		"bluesky":   screenshot.PlatformPresets["bluesky"],
+		"instagram": screenshot.PlatformPresets["instagram"],
+	}
+	p.primary = "bluesky"
+	p.capture = fakeCapture(tempDir, &inFlight, &maxInFlight)
+
+	summary, err := p.run(context.Background(), context.Background(), mf.GetEntriesWithoutScreenshots())
+	require.NoError(t, err)
+	assert.Equal(t, runSummary{captured: 1}, summary)
+	assert.Equal(t, "day-1-screenshot-bluesky.png", mf.Entries[0].Screenshot)
+
+	for filename, exists := range map[string]bool{
+		"day-1-screenshot.png":           false,
+		"day-1-screenshot-bluesky.png":   true,
+		"day-1-screenshot-instagram.png": true,
+		"day-1-screenshot-twitter.png":   false,
+	} {
+		_, err := os.Stat(filepath.Join(tempDir, filename))
+		assert.Equal(t, exists, err == nil, filename)
+	}
+}
+
+func normalizeDates(content string) string {
+	return screenshotErrorDate.ReplaceAllString(content, "(DATE)")
+}
+
+func stripDirs(output string) string {
+	return savedPath.ReplaceAllString(output, "saved ")
+}
+
+func TestPipelineStopsDispatchingWhenStopped(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	require.NoError(t, os.WriteFile(testFile, []byte(pipelineInput), 0644))
+
+	mf, err := markdown.ParseMarkdownFile(testFile)
+	require.NoError(t, err)
+
+	stop, cancelStop := context.WithCancel(context.Background())
+	defer cancelStop()
+
+	var out bytes.Buffer
+	p := newPipeline(&out, mf, tempDir, 1)
+	p.capture = func(ctx context.Context, entry markdown.DayEntry, filename string, config screenshot.ScreenshotConfig) error {
+		// The first capture is in flight when the interrupt arrives and must
+		// still complete
+		cancelStop()
+		return imaging.Save(image.NewRGBA(image.Rect(0, 0, 80, 60)), filepath.Join(tempDir, filename))
+	}
+
+	summary, err := p.run(stop, context.Background(), mf.GetEntriesWithoutScreenshots())
+	require.NoError(t, err)
+	assert.Equal(t, runSummary{captured: 1, skipped: 4}, summary)
+	require.NoError(t, mf.WriteMarkdownFile())
+
+	content, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Screen Shot: day-1-screenshot.png")
+	assert.NotContains(t, string(content), "day-3-screenshot.png")
+	assert.NotContains(t, string(content), "Screenshot Error:")
+}
+
+func TestPipelineAbortedCapturesAreSkipped(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	require.NoError(t, os.WriteFile(testFile, []byte(pipelineInput), 0644))
+
+	mf, err := markdown.ParseMarkdownFile(testFile)
+	require.NoError(t, err)
+
+	stop, cancelStop := context.WithCancel(context.Background())
+	abort, cancelAbort := context.WithCancel(context.Background())
+	defer cancelStop()
+
+	var out bytes.Buffer
+	p := newPipeline(&out, mf, tempDir, 2)
+	p.capture = func(ctx context.Context, entry markdown.DayEntry, filename string, config screenshot.ScreenshotConfig) error {
+		cancelStop()
+		cancelAbort()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	summary, err := p.run(stop, abort, mf.GetEntriesWithoutScreenshots())
+	require.NoError(t, err)
+	assert.Equal(t, 0, summary.captured)
+	assert.Equal(t, 0, summary.failed)
+	assert.Equal(t, 5, summary.skipped)
+
+	for _, entry := range mf.Entries {
+		assert.Empty(t, entry.Error)
+		assert.False(t, entry.HasScreenshot)
+	}
+}
+
+func TestPipelineCapturesColorSchemes(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "tweets.md")
+	content := "## Day 1\n- URL: https://example.com/1\n\n## Day 2\n- URL: https://example.com/2\n- Color Scheme: dark\n"
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	mf, err := markdown.ParseMarkdownFile(testFile)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	var inFlight, maxInFlight int32
+	captured := make(map[string]string)
+	p := newPipeline(&out, mf, tempDir, 1)
+	p.colorSchemes = []string{"light", "dark"}
+	capture := fakeCapture(tempDir, &inFlight, &maxInFlight)
+	p.capture = func(ctx context.Context, entry markdown.DayEntry, filename string, config screenshot.ScreenshotConfig) error {
+		captured[filename] = config.ColorScheme
+		return capture(ctx, entry, filename, config)
+	}
+
+	summary, err := p.run(context.Background(), context.Background(), mf.GetEntriesWithoutScreenshots())
+	require.NoError(t, err)
+	assert.Equal(t, runSummary{captured: 2}, summary)
+
+	assert.Equal(t, map[string]string{
+		"day-1-screenshot.png":      "light",
+		"day-1-screenshot-dark.png": "dark",
+		"day-2-screenshot.png":      "dark",
+	}, captured)
+	assert.Equal(t, "day-1-screenshot.png", mf.Entries[0].Screenshot)
+	assert.FileExists(t, filepath.Join(tempDir, "day-1-screenshot-dark-twitter.png"))
+}
+
+func TestPipelineNormalizesHighResolutionOriginals(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		capturer      string
+		normalize     bool
+		originalWidth int
+	}{
+		{"native", "browser", false, 1600},
+		{"normalized", "browser", true, 800},
+		{"thumbnails keep their size", "youtube", true, 1600},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			testFile := filepath.Join(tempDir, "tweets.md")
+			require.NoError(t, os.WriteFile(testFile, []byte("## Day 1\n- URL: https://example.com/1\n"), 0644))
+
+			mf, err := markdown.ParseMarkdownFile(testFile)
+			require.NoError(t, err)
+
+			var out bytes.Buffer
+			p := newPipeline(&out, mf, tempDir, 1)
+			p.config.Scale = 2
+			p.normalize = test.normalize
+			p.capture = func(ctx context.Context, entry markdown.DayEntry, filename string, config screenshot.ScreenshotConfig) error {
+				img := image.NewRGBA(image.Rect(0, 0, 1600, 1200))
+				if err := imaging.Save(img, filepath.Join(tempDir, filename)); err != nil {
+					return err
+				}
+				config.OnCaptured(test.capturer)
+				return nil
+			}
+
+			_, err = p.run(context.Background(), context.Background(), mf.GetEntriesWithoutScreenshots())
+			require.NoError(t, err)
+
+			original, err := imaging.Open(filepath.Join(tempDir, "day-1-screenshot.png"))
+			require.NoError(t, err)
+			assert.Equal(t, test.originalWidth, original.Bounds().Dx())
+			assert.Equal(t, test.originalWidth*3/4, original.Bounds().Dy())
+
+			twitter, err := imaging.Open(filepath.Join(tempDir, "day-1-screenshot-twitter.png"))
+			require.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, 1200, 628), twitter.Bounds())
+		})
+	}
+}
+
+func TestEntryConfig(t *testing.T) {
+	base := screenshot.NewDefaultConfig()
+	base.Selector = "article"
+	base.FullPage = true
+
+	assert.Equal(t, base, entryConfig(base, markdown.DayEntry{Day: 1}))
+
+	fullPage := false
+	config := entryConfig(base, markdown.DayEntry{Day: 2, Selector: "pre", FullPage: &fullPage, Wait: []string{"selector:pre"}})
+	assert.Equal(t, "pre", config.Selector)
+	assert.False(t, config.FullPage)
+	assert.Equal(t, base.ViewportWidth, config.ViewportWidth)
+	assert.Equal(t, []screenshot.WaitStep{{Kind: screenshot.WaitSelector, Value: "pre"}}, config.Wait)
+}
@@ -1,27 +1,177 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"screenshot-tweets/screenshot"
+
+	"gopkg.in/yaml.v3"
 )
 
+// FileName is the config file looked up next to the markdown file.
+const FileName = ".screenshot-tweets.yaml"
+
+// OriginalFormat is the output format that keeps the unresized screenshot.
+const OriginalFormat = "original"
+
+// Original resolutions: the size the screenshot was captured at, or its
+// size in CSS pixels.
+const (
+	NativeResolution     = "native"
+	NormalizedResolution = "normalized"
+)
+
+// MaxScale is the highest device pixel ratio screenshots can be captured at.
+const MaxScale = 4
+
+const defaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
 type Config struct {
-	BrowserPath    string        `json:"browser_path"`
-	DefaultTimeout time.Duration `json:"default_timeout"`
-	MaxRetries     int           `json:"max_retries"`
-	UserAgent      string        `json:"user_agent"`
-	OutputFormats  []string      `json:"output_formats"`
+	BrowserPath    string        `json:"browser_path" yaml:"browser_path"`
+	DefaultTimeout time.Duration `json:"default_timeout" yaml:"default_timeout"`
+	MaxRetries     int           `json:"max_retries" yaml:"max_retries"`
+	UserAgent      string        `json:"user_agent" yaml:"user_agent"`
+	OutputFormats  []string      `json:"output_formats" yaml:"output_formats"`
+	ViewportWidth  int           `json:"viewport_width" yaml:"viewport_width"`
+	ViewportHeight int           `json:"viewport_height" yaml:"viewport_height"`
+	OutputDir      string        `json:"output_dir" yaml:"output_dir"`
+	Concurrency    int           `json:"concurrency" yaml:"concurrency"`
+	CaptureOrder   []string      `json:"capture_order" yaml:"capture_order"`
+
+	// OGImageMinWidth and OGImageMinHeight are the smallest og:image or
+	// twitter:image the opengraph capture strategy accepts, in pixels.
+	OGImageMinWidth  int `json:"og_image_min_width" yaml:"og_image_min_width"`
+	OGImageMinHeight int `json:"og_image_min_height" yaml:"og_image_min_height"`
+
+	// Device emulates a device from the presets or Devices, overriding the
+	// viewport and user agent. Devices declared under a preset's key replace
+	// that preset.
+	Device  string                       `json:"device" yaml:"device"`
+	Devices map[string]screenshot.Device `json:"devices" yaml:"devices"`
+
+	// Scale is the device pixel ratio to capture at, 0 for the device's.
+	// OriginalResolution keeps the original at the captured "native" size
+	// or scales it back to CSS pixels when "normalized".
+	Scale              float64 `json:"scale" yaml:"scale"`
+	OriginalResolution string  `json:"original_resolution" yaml:"original_resolution"`
+
+	// Selector clips every screenshot to the first matching element,
+	// unless an entry sets its own. SelectorPadding adds CSS pixels around it.
+	Selector        string `json:"selector" yaml:"selector"`
+	SelectorPadding int    `json:"selector_padding" yaml:"selector_padding"`
+
+	// FullPage captures whole pages, cut off at MaxPageHeight CSS pixels.
+	FullPage      bool `json:"full_page" yaml:"full_page"`
+	MaxPageHeight int  `json:"max_page_height" yaml:"max_page_height"`
+
+	// PDFPage is the page rendered for entries that link to a PDF, unless
+	// the URL has a "#page=N" fragment.
+	PDFPage int `json:"pdf_page" yaml:"pdf_page"`
+
+	// HighlightText highlights the text a text fragment ("#:~:text=") in
+	// an entry URL points at.
+	HighlightText bool `json:"highlight_text" yaml:"highlight_text"`
+
+	// ColorSchemes lists the color schemes to capture every entry in, the
+	// first one under the usual filename. Empty leaves the browser default.
+	ColorSchemes  []string `json:"color_schemes" yaml:"color_schemes"`
+	ReducedMotion bool     `json:"reduced_motion" yaml:"reduced_motion"`
+	MediaType     string   `json:"media_type" yaml:"media_type"`
+
+	// Wait lists the steps to wait for once a page has loaded, such as
+	// "selector:#app" or "delay:2s". Empty uses the default steps and
+	// "none" waits for the load alone.
+	Wait []string `json:"wait" yaml:"wait"`
+
+	// Inject lists CSS and JavaScript to inject into every page, or the
+	// pages of a domain pattern, before capturing. InjectStrict fails the
+	// captures whose injections fail instead of reporting them.
+	Inject       []Injection `json:"inject" yaml:"inject"`
+	InjectStrict bool        `json:"inject_strict" yaml:"inject_strict"`
+
+	// DismissOverlays applies the built-in overlay rules plus those in
+	// OverlayRulesFile before every screenshot. It is off by default, since
+	// rules click consent buttons.
+	DismissOverlays  bool   `json:"dismiss_overlays" yaml:"dismiss_overlays"`
+	OverlayRulesFile string `json:"overlay_rules_file" yaml:"overlay_rules_file"`
+
+	// Requests matching the blocklist file (hosts or Adblock syntax) or the
+	// Block* lists are blocked while pages load.
+	BlocklistFile      string   `json:"blocklist_file" yaml:"blocklist_file"`
+	BlockDomains       []string `json:"block_domains" yaml:"block_domains"`
+	BlockResourceTypes []string `json:"block_resource_types" yaml:"block_resource_types"`
+	BlockURLPatterns   []string `json:"block_url_patterns" yaml:"block_url_patterns"`
+
+	// CookieFile is a Netscape cookies.txt or JSON cookie file whose cookies
+	// are sent to the pages of their domains.
+	CookieFile string `json:"cookie_file" yaml:"cookie_file"`
+
+	// Proxy is an http, https or socks5 proxy URL for the browser and for
+	// downloads, bypassed for the hosts in ProxyBypass. Credentials can be
+	// read from SCREENSHOT_PROXY_USERNAME and SCREENSHOT_PROXY_PASSWORD.
+	Proxy       string   `json:"proxy" yaml:"proxy"`
+	ProxyBypass []string `json:"proxy_bypass" yaml:"proxy_bypass"`
+
+	// Sites add request headers and basic auth credentials to the requests
+	// for hosts matching their key, a host name or "*.example.com".
+	Sites map[string]Site `json:"sites" yaml:"sites"`
+
+	// Platforms declares social media platforms on top of the built-in
+	// presets. Keys that match a preset inherit its values for any field
+	// left unset.
+	Platforms map[string]screenshot.SocialMediaPlatform `json:"platforms" yaml:"platforms"`
+
+	// sources maps a field's yaml name to where its value came from
+	sources map[string]string
+
+	// The files read by Validate are cached by path, so the run that
+	// follows does not read them again
+	overlayRulesPath string
+	overlayFileRules []screenshot.OverlayRule
+	blocklistPath    string
+	fileBlocklist    *screenshot.Blocklist
+	cookiePath       string
+	cookieJar        *screenshot.CookieJar
+	injectionFiles   map[string]screenshot.Injection
 }
 
+// Site holds the request headers and basic auth credentials for a domain
+// pattern. Secrets come from the environment: header values can reference
+// variables as ${NAME}, and the password is read from PasswordEnv.
+type Site struct {
+	Headers     map[string]string `json:"headers" yaml:"headers"`
+	Username    string            `json:"username" yaml:"username"`
+	UsernameEnv string            `json:"username_env" yaml:"username_env"`
+	PasswordEnv string            `json:"password_env" yaml:"password_env"`
+}
+
+// Injection is a CSS or JavaScript file, or inline CSS or JavaScript, to
+// inject into the pages whose host matches Domain, or every page when
+// Domain is empty.
+type Injection struct {
+	Domain string `json:"domain" yaml:"domain"`
+	File   string `json:"file" yaml:"file"`
+	CSS    string `json:"css" yaml:"css"`
+	JS     string `json:"js" yaml:"js"`
+}
+
+// LoadConfig returns the defaults overridden by SCREENSHOT_* environment
+// variables, validated.
 func LoadConfig() (*Config, error) {
-	config := &Config{
-		UserAgent:      getEnvWithDefault("SCREENSHOT_USER_AGENT", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
-		DefaultTimeout: getTimeoutFromEnv("SCREENSHOT_DEFAULT_TIMEOUT", 30*time.Second),
-		OutputFormats:  []string{"original", "twitter", "linkedin"},
-		BrowserPath:    getEnvWithDefault("SCREENSHOT_BROWSER_PATH", ""),
-		MaxRetries:     getIntFromEnv("SCREENSHOT_MAX_RETRIES", 3),
+	config, err := Load("")
+	if err != nil {
+		return nil, err
 	}
 
 	if err := config.Validate(); err != nil {
@@ -31,60 +181,733 @@ func LoadConfig() (*Config, error) {
 	return config, nil
 }
 
+// Load layers the config file at path (skipped when empty) and then the
+// environment over the defaults. The result is not validated so callers
+// can apply the markdown Formats: line and then flags on top before calling
+// Validate.
+func Load(path string) (*Config, error) {
+	config := DefaultConfig()
+
+	if path != "" {
+		if err := config.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// FindConfigFile returns the config file to use for a markdown file in
+// markdownDir: FileName in that directory, otherwise
+// screenshot-tweets/config.yaml in the user config directory
+// ($XDG_CONFIG_HOME or ~/.config). It returns "" when neither exists.
+func FindConfigFile(markdownDir string) string {
+	candidates := []string{filepath.Join(markdownDir, FileName)}
+	if dir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, "screenshot-tweets", "config.yaml"))
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var keys map[string]any
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	for key := range keys {
+		c.SetSource(key, "config file "+path)
+	}
+
+	// Relative paths are relative to the config file
+	for key, value := range map[string]*string{
+		"output_dir":         &c.OutputDir,
+		"overlay_rules_file": &c.OverlayRulesFile,
+		"blocklist_file":     &c.BlocklistFile,
+		"cookie_file":        &c.CookieFile,
+	} {
+		if _, ok := keys[key]; ok && *value != "" && !filepath.IsAbs(*value) {
+			*value = filepath.Join(filepath.Dir(path), *value)
+		}
+	}
+	if _, ok := keys["inject"]; ok {
+		for i, injection := range c.Inject {
+			if injection.File != "" && !filepath.IsAbs(injection.File) {
+				c.Inject[i].File = filepath.Join(filepath.Dir(path), injection.File)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadEnv reads the SCREENSHOT_* environment variables, failing on values
+// that do not parse so they are not silently ignored.
+func (c *Config) loadEnv() error {
+	if value, ok := getEnv("SCREENSHOT_USER_AGENT"); ok {
+		c.UserAgent = value
+		c.SetSource("user_agent", "env SCREENSHOT_USER_AGENT")
+	}
+
+	if value, ok, err := getTimeoutFromEnv("SCREENSHOT_DEFAULT_TIMEOUT"); err != nil {
+		return err
+	} else if ok {
+		c.DefaultTimeout = value
+		c.SetSource("default_timeout", "env SCREENSHOT_DEFAULT_TIMEOUT")
+	}
+
+	if value, ok := getEnv("SCREENSHOT_BROWSER_PATH"); ok {
+		c.BrowserPath = value
+		c.SetSource("browser_path", "env SCREENSHOT_BROWSER_PATH")
+	}
+
+	if value, ok, err := getIntFromEnv("SCREENSHOT_MAX_RETRIES"); err != nil {
+		return err
+	} else if ok {
+		c.MaxRetries = value
+		c.SetSource("max_retries", "env SCREENSHOT_MAX_RETRIES")
+	}
+
+	if value, ok := getEnv("SCREENSHOT_PROXY"); ok {
+		c.Proxy = value
+		c.SetSource("proxy", "env SCREENSHOT_PROXY")
+	}
+
+	return nil
+}
+
+// SetSource records where the value of field (its yaml name) came from,
+// e.g. "flag --width". Validate includes it in its errors.
+func (c *Config) SetSource(field, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[field] = source
+}
+
+// Source returns where the value of field came from, or "default".
+func (c *Config) Source(field string) string {
+	if source, ok := c.sources[field]; ok {
+		return source
+	}
+	return "default"
+}
+
 func (c *Config) Validate() error {
 	if c.DefaultTimeout <= 0 {
-		return fmt.Errorf("default timeout must be positive")
+		return c.fieldError("default_timeout", "default timeout must be positive")
 	}
 
 	if c.MaxRetries < 0 {
-		return fmt.Errorf("max retries cannot be negative")
+		return c.fieldError("max_retries", "max retries cannot be negative")
 	}
 
 	if c.UserAgent == "" {
-		return fmt.Errorf("user agent cannot be empty")
+		return c.fieldError("user_agent", "user agent cannot be empty")
 	}
 
 	if c.BrowserPath != "" {
 		if _, err := os.Stat(c.BrowserPath); os.IsNotExist(err) {
-			return fmt.Errorf("specified browser path does not exist: %s", c.BrowserPath)
+			return c.fieldError("browser_path", fmt.Sprintf("specified browser path does not exist: %s", c.BrowserPath))
+		}
+	}
+
+	if c.ViewportWidth <= 0 {
+		return c.fieldError("viewport_width", fmt.Sprintf("viewport width must be positive, got %d", c.ViewportWidth))
+	}
+
+	if c.ViewportHeight <= 0 {
+		return c.fieldError("viewport_height", fmt.Sprintf("viewport height must be positive, got %d", c.ViewportHeight))
+	}
+
+	if c.Concurrency < 1 {
+		return c.fieldError("concurrency", fmt.Sprintf("concurrency must be at least 1, got %d", c.Concurrency))
+	}
+
+	devices, err := c.ResolveDevices()
+	if err != nil {
+		return c.FieldError("devices", err)
+	}
+	if _, ok := devices[c.Device]; c.Device != "" && !ok {
+		return c.fieldError("device", fmt.Sprintf("unknown device %q", c.Device))
+	}
+
+	if c.Scale < 0 || c.Scale > MaxScale {
+		return c.fieldError("scale", fmt.Sprintf("scale must be between 0 and %d, got %g", MaxScale, c.Scale))
+	}
+
+	if c.OriginalResolution != NativeResolution && c.OriginalResolution != NormalizedResolution {
+		return c.fieldError("original_resolution", fmt.Sprintf("invalid original resolution %q, use %s or %s",
+			c.OriginalResolution, NativeResolution, NormalizedResolution))
+	}
+
+	if c.SelectorPadding < 0 {
+		return c.fieldError("selector_padding", fmt.Sprintf("selector padding cannot be negative, got %d", c.SelectorPadding))
+	}
+
+	if c.OGImageMinWidth < 0 {
+		return c.fieldError("og_image_min_width", fmt.Sprintf("og:image minimum width cannot be negative, got %d", c.OGImageMinWidth))
+	}
+
+	if c.OGImageMinHeight < 0 {
+		return c.fieldError("og_image_min_height", fmt.Sprintf("og:image minimum height cannot be negative, got %d", c.OGImageMinHeight))
+	}
+
+	if c.PDFPage < 1 {
+		return c.fieldError("pdf_page", fmt.Sprintf("PDF page must be at least 1, got %d", c.PDFPage))
+	}
+
+	if c.MaxPageHeight <= 0 {
+		return c.fieldError("max_page_height", fmt.Sprintf("max page height must be positive, got %d", c.MaxPageHeight))
+	}
+
+	seen := make(map[string]bool)
+	for _, scheme := range c.ColorSchemes {
+		if scheme != "light" && scheme != "dark" {
+			return c.fieldError("color_schemes", fmt.Sprintf("invalid color scheme %q, use light or dark", scheme))
+		}
+		if seen[scheme] {
+			return c.fieldError("color_schemes", fmt.Sprintf("color scheme %q listed more than once", scheme))
 		}
+		seen[scheme] = true
+	}
+
+	if c.MediaType != "" && c.MediaType != "screen" && c.MediaType != "print" {
+		return c.fieldError("media_type", fmt.Sprintf("invalid media type %q, use screen or print", c.MediaType))
+	}
+
+	if _, err := c.WaitSteps(); err != nil {
+		return c.FieldError("wait", err)
+	}
+
+	if _, err := c.Injections(); err != nil {
+		return c.FieldError("inject", err)
+	}
+
+	if _, err := c.OverlayRules(); err != nil {
+		return c.FieldError("overlay_rules_file", err)
+	}
+
+	if _, err := c.Blocklist(); err != nil {
+		return err
+	}
+
+	if _, err := c.ResolveProxy(); err != nil {
+		return c.FieldError("proxy", err)
+	}
+
+	if _, err := c.SiteRules(); err != nil {
+		return c.FieldError("sites", err)
+	}
+
+	if _, err := c.Cookies(); err != nil {
+		return c.FieldError("cookie_file", err)
+	}
+
+	if _, err := c.declaredPlatforms(); err != nil {
+		return c.FieldError("platforms", err)
+	}
+
+	if _, err := c.ResolvePlatforms(); err != nil {
+		return c.FieldError("output_formats", err)
 	}
 
 	return nil
 }
 
-func getEnvWithDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+// ResolveDevices returns the devices that can be emulated: the built-in
+// presets and the declared Devices, which default to the configured user
+// agent.
+func (c *Config) ResolveDevices() (map[string]screenshot.Device, error) {
+	devices := make(map[string]screenshot.Device, len(screenshot.DevicePresets)+len(c.Devices))
+	for key, device := range screenshot.DevicePresets {
+		devices[key] = device
+	}
+
+	for key, device := range c.Devices {
+		if device.Name == "" {
+			device.Name = key
+		}
+		if device.UserAgent == "" {
+			device.UserAgent = c.UserAgent
+		}
+		if err := device.Validate(); err != nil {
+			return nil, fmt.Errorf("device %q: %w", key, err)
+		}
+		devices[key] = device
+	}
+
+	return devices, nil
+}
+
+// OverlayRules returns the overlay rules to apply: none when DismissOverlays
+// is off, otherwise the built-in rules followed by those in OverlayRulesFile.
+// The file is read once.
+func (c *Config) OverlayRules() ([]screenshot.OverlayRule, error) {
+	if !c.DismissOverlays {
+		return nil, nil
+	}
+
+	rules := append([]screenshot.OverlayRule(nil), screenshot.DefaultOverlayRules...)
+	if c.OverlayRulesFile == "" {
+		return rules, nil
+	}
+
+	if c.overlayRulesPath != c.OverlayRulesFile {
+		fileRules, err := LoadOverlayRules(c.OverlayRulesFile)
+		if err != nil {
+			return nil, err
+		}
+		c.overlayRulesPath, c.overlayFileRules = c.OverlayRulesFile, fileRules
+	}
+	return append(rules, c.overlayFileRules...), nil
+}
+
+// LoadOverlayRules reads a YAML list of overlay rules from path.
+func LoadOverlayRules(path string) ([]screenshot.OverlayRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read overlay rules %s: %w", path, err)
+	}
+
+	var rules []screenshot.OverlayRule
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&rules); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse overlay rules %s: %w", path, err)
+	}
+
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid overlay rules %s: %w", path, err)
+		}
+	}
+
+	return rules, nil
+}
+
+// Blocklist returns the requests to block, or nil when nothing is blocked.
+// Errors name the setting they come from.
+func (c *Config) Blocklist() (*screenshot.Blocklist, error) {
+	blocklist := screenshot.NewBlocklist()
+
+	if c.BlocklistFile != "" {
+		if c.blocklistPath != c.BlocklistFile {
+			fileBlocklist, err := screenshot.LoadBlocklist(c.BlocklistFile)
+			if err != nil {
+				return nil, c.FieldError("blocklist_file", err)
+			}
+			c.blocklistPath, c.fileBlocklist = c.BlocklistFile, fileBlocklist
+		}
+		blocklist.Merge(c.fileBlocklist)
+	}
+
+	for _, domain := range c.BlockDomains {
+		blocklist.AddDomain(domain)
+	}
+
+	for _, resourceType := range c.BlockResourceTypes {
+		if err := blocklist.AddResourceType(resourceType); err != nil {
+			return nil, c.FieldError("block_resource_types", err)
+		}
+	}
+
+	for _, pattern := range c.BlockURLPatterns {
+		if err := blocklist.AddPattern(pattern); err != nil {
+			return nil, c.FieldError("block_url_patterns", err)
+		}
+	}
+
+	if blocklist.Len() == 0 {
+		return nil, nil
+	}
+	return blocklist, nil
+}
+
+// ResolveProxy returns the proxy to use, or nil when there is none. The
+// SCREENSHOT_PROXY_USERNAME and SCREENSHOT_PROXY_PASSWORD environment
+// variables override the credentials in the proxy URL.
+func (c *Config) ResolveProxy() (*screenshot.Proxy, error) {
+	if c.Proxy == "" {
+		return nil, nil
+	}
+
+	proxy, err := screenshot.ParseProxy(c.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	if username, ok := getEnv("SCREENSHOT_PROXY_USERNAME"); ok {
+		proxy.URL.User = url.UserPassword(username, os.Getenv("SCREENSHOT_PROXY_PASSWORD"))
+	}
+	proxy.Bypass = c.ProxyBypass
+
+	if err := proxy.Validate(); err != nil {
+		return nil, err
+	}
+	return proxy, nil
+}
+
+// SiteRules returns the Sites with their secrets read from the
+// environment, ordered so that more specific (longer) patterns override
+// the others.
+func (c *Config) SiteRules() (screenshot.SiteRules, error) {
+	patterns := make([]string, 0, len(c.Sites))
+	for pattern := range c.Sites {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) < len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	rules := make(screenshot.SiteRules, 0, len(patterns))
+	for _, pattern := range patterns {
+		rule, err := c.Sites[pattern].rule(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("site %q: %w", pattern, err)
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, fmt.Errorf("site %q: %w", pattern, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// envReference matches the "${NAME}" references to environment variables
+// in site header values. Other dollar signs are kept as they are.
+var envReference = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+func (s Site) rule(pattern string) (screenshot.SiteRule, error) {
+	rule := screenshot.SiteRule{Domain: pattern, Username: s.Username}
+
+	var missing []string
+	lookup := func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
 		return value
 	}
-	return defaultValue
+
+	if len(s.Headers) > 0 {
+		rule.Headers = make(map[string]string, len(s.Headers))
+		for name, value := range s.Headers {
+			rule.Headers[name] = envReference.ReplaceAllStringFunc(value, func(ref string) string {
+				return lookup(ref[2 : len(ref)-1])
+			})
+		}
+	}
+	if s.UsernameEnv != "" {
+		rule.Username = lookup(s.UsernameEnv)
+	}
+	if s.PasswordEnv != "" {
+		rule.Password = lookup(s.PasswordEnv)
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return rule, fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	if s.PasswordEnv != "" && rule.Username == "" {
+		return rule, fmt.Errorf("password_env needs username or username_env")
+	}
+	return rule, nil
 }
 
-func getIntFromEnv(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if parsed, err := strconv.Atoi(value); err == nil {
-			return parsed
+// Cookies returns the cookies of CookieFile, or nil when there is none.
+// The file is read once.
+func (c *Config) Cookies() (*screenshot.CookieJar, error) {
+	if c.CookieFile == "" {
+		return nil, nil
+	}
+
+	if c.cookiePath != c.CookieFile {
+		jar, err := screenshot.LoadCookieJar(c.CookieFile)
+		if err != nil {
+			return nil, err
 		}
+		c.cookiePath, c.cookieJar = c.CookieFile, jar
 	}
-	return defaultValue
+	return c.cookieJar, nil
 }
 
-func getTimeoutFromEnv(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if parsed, err := time.ParseDuration(value); err == nil {
-			return parsed
+// WaitSteps returns the parsed Wait steps, or nil for the defaults.
+func (c *Config) WaitSteps() ([]screenshot.WaitStep, error) {
+	if len(c.Wait) == 0 {
+		return nil, nil
+	}
+	return screenshot.ParseWaitSteps(c.Wait)
+}
+
+// Injections loads the Inject files and returns every injection in order.
+// Inline injections are named after their position, e.g. "inject[0]". Each
+// file is read once.
+func (c *Config) Injections() ([]screenshot.Injection, error) {
+	injections := make([]screenshot.Injection, 0, len(c.Inject))
+	for i, inject := range c.Inject {
+		if (inject.File != "") == (inject.CSS != "" || inject.JS != "") || (inject.CSS != "" && inject.JS != "") {
+			return nil, fmt.Errorf("inject[%d] needs exactly one of file, css or js", i)
+		}
+
+		injection := screenshot.Injection{Source: fmt.Sprintf("inject[%d]", i), CSS: inject.CSS, JS: inject.JS}
+		if inject.File != "" {
+			loaded, ok := c.injectionFiles[inject.File]
+			if !ok {
+				var err error
+				if loaded, err = screenshot.LoadInjection(inject.File); err != nil {
+					return nil, err
+				}
+				if c.injectionFiles == nil {
+					c.injectionFiles = make(map[string]screenshot.Injection)
+				}
+				c.injectionFiles[inject.File] = loaded
+			}
+			injection = loaded
+		}
+		injection.Domain = inject.Domain
+
+		if err := injection.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", injection, err)
+		}
+		injections = append(injections, injection)
+	}
+	return injections, nil
+}
+
+// KeepOriginal reports whether OutputFormats asks for the unresized
+// screenshot to be kept.
+func (c *Config) KeepOriginal() bool {
+	for _, format := range c.OutputFormats {
+		if format == OriginalFormat {
+			return true
+		}
+	}
+	return false
+}
+
+// Formats returns the platform keys selected by OutputFormats, in order and
+// without OriginalFormat. When output_formats is left at its default, the
+// declared platforms that are not among the defaults follow them, sorted.
+func (c *Config) Formats() []string {
+	var keys []string
+	for _, format := range c.OutputFormats {
+		if format != OriginalFormat {
+			keys = append(keys, format)
+		}
+	}
+	if c.Source("output_formats") != "default" {
+		return keys
+	}
+
+	var declared []string
+	for key := range c.Platforms {
+		if !slices.Contains(keys, key) {
+			declared = append(declared, key)
+		}
+	}
+	sort.Strings(declared)
+	return append(keys, declared...)
+}
+
+// ResolvePlatforms returns the platforms to generate variants for, picked by
+// Formats from the built-in presets and the declared Platforms.
+func (c *Config) ResolvePlatforms() (map[string]screenshot.SocialMediaPlatform, error) {
+	declared, err := c.declaredPlatforms()
+	if err != nil {
+		return nil, err
+	}
+
+	formats := c.Formats()
+	if len(formats) == 0 && !c.KeepOriginal() {
+		return nil, fmt.Errorf("no output formats selected")
+	}
+
+	platforms := make(map[string]screenshot.SocialMediaPlatform, len(formats))
+	for _, format := range formats {
+		platform, ok := declared[format]
+		if !ok {
+			platform, ok = screenshot.PlatformPresets[format]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown output format %q", format)
+		}
+		platforms[format] = platform
+	}
+
+	return platforms, nil
+}
+
+// declaredPlatforms returns the declared Platforms completed from the
+// built-in presets.
+func (c *Config) declaredPlatforms() (map[string]screenshot.SocialMediaPlatform, error) {
+	platforms := make(map[string]screenshot.SocialMediaPlatform, len(c.Platforms))
+	for key, platform := range c.Platforms {
+		if preset, ok := screenshot.PlatformPresets[key]; ok {
+			if platform.Name == "" {
+				platform.Name = preset.Name
+			}
+			if platform.Width == 0 {
+				platform.Width = preset.Width
+			}
+			if platform.Height == 0 {
+				platform.Height = preset.Height
+			}
+			if platform.Suffix == "" {
+				platform.Suffix = preset.Suffix
+			}
+			if platform.Format == "" {
+				platform.Format = preset.Format
+			}
+		}
+
+		if platform.Name == "" {
+			platform.Name = key
+		}
+
+		if err := platform.Validate(); err != nil {
+			return nil, fmt.Errorf("platform %q: %w", key, err)
+		}
+		platforms[key] = platform
+	}
+
+	return platforms, nil
+}
+
+// FieldError wraps err with the source of field, for errors found outside
+// Validate.
+func (c *Config) FieldError(field string, err error) error {
+	if source, ok := c.sources[field]; ok {
+		return fmt.Errorf("%w (from %s)", err, source)
+	}
+	return err
+}
+
+func (c *Config) fieldError(field, message string) error {
+	return c.FieldError(field, fmt.Errorf("%s", message))
+}
+
+// ScreenshotConfig returns the capture settings, writing next to the
+// markdown file in markdownDir unless an output directory is configured.
+func (c *Config) ScreenshotConfig(markdownDir string) screenshot.ScreenshotConfig {
+	outputDir := c.OutputDir
+	if outputDir == "" {
+		outputDir = markdownDir
+	}
+
+	var colorScheme string
+	if len(c.ColorSchemes) > 0 {
+		colorScheme = c.ColorSchemes[0]
+	}
+
+	config := screenshot.ScreenshotConfig{
+		ViewportWidth:  c.ViewportWidth,
+		ViewportHeight: c.ViewportHeight,
+		Timeout:        c.DefaultTimeout,
+		OutputDir:      outputDir,
+		UserAgent:      c.UserAgent,
+		BrowserPath:    c.BrowserPath,
+		Selector:       c.Selector,
+		Padding:        c.SelectorPadding,
+		FullPage:       c.FullPage,
+		MaxHeight:      c.MaxPageHeight,
+		ColorScheme:    colorScheme,
+		ReducedMotion:  c.ReducedMotion,
+		MediaType:      c.MediaType,
+		Scale:          c.Scale,
+
+		HighlightText:     c.HighlightText,
+		PDFPage:           c.PDFPage,
+		OGImageMinWidth:   c.OGImageMinWidth,
+		OGImageMinHeight:  c.OGImageMinHeight,
+		FailOnInjectError: c.InjectStrict,
+	}
+
+	if steps, err := c.WaitSteps(); err == nil {
+		config.Wait = steps
+	}
+
+	if c.Device != "" {
+		if devices, err := c.ResolveDevices(); err == nil {
+			config = devices[c.Device].Apply(config)
 		}
 	}
-	return defaultValue
+
+	return config
+}
+
+func getEnv(key string) (string, bool) {
+	if value := os.Getenv(key); value != "" {
+		return value, true
+	}
+	return "", false
+}
+
+func getIntFromEnv(key string) (int, bool, error) {
+	value, ok := getEnv(key)
+	if !ok {
+		return 0, false, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid number %q (from env %s)", value, key)
+	}
+	return parsed, true, nil
+}
+
+func getTimeoutFromEnv(key string) (time.Duration, bool, error) {
+	value, ok := getEnv(key)
+	if !ok {
+		return 0, false, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid duration %q, e.g. 45s (from env %s)", value, key)
+	}
+	return parsed, true, nil
 }
 
 func DefaultConfig() *Config {
 	return &Config{
-		BrowserPath:    "",
-		DefaultTimeout: 30 * time.Second,
-		MaxRetries:     3,
-		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-		OutputFormats:  []string{"original", "twitter", "linkedin"},
+		BrowserPath:        "",
+		DefaultTimeout:     30 * time.Second,
+		MaxRetries:         3,
+		UserAgent:          defaultUserAgent,
+		OutputFormats:      []string{OriginalFormat, "twitter", "linkedin"},
+		ViewportWidth:      800,
+		ViewportHeight:     600,
+		OutputDir:          "",
+		Concurrency:        1,
+		MaxPageHeight:      screenshot.DefaultMaxHeight,
+		PDFPage:            1,
+		OGImageMinWidth:    600,
+		OGImageMinHeight:   315,
+		OriginalResolution: NativeResolution,
 	}
 }
-
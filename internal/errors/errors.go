@@ -19,6 +19,12 @@ func (e ScreenshotError) Error() string {
 	return fmt.Sprintf("screenshot error (Day %d, %s): %s - %s", e.Day, e.ErrorType, e.URL, e.Message)
 }
 
+// Annotation formats the error for a markdown "Screenshot Error:" line,
+// e.g. "timeout (2026-10-16T10:00Z)".
+func (e ScreenshotError) Annotation() string {
+	return fmt.Sprintf("%s (%s)", e.ErrorType, e.Timestamp.UTC().Format("2006-01-02T15:04Z"))
+}
+
 func NewScreenshotError(url string, day int, err error) *ScreenshotError {
 	errorType := categorizeError(err)
 	return &ScreenshotError{
@@ -105,7 +111,12 @@ type RetryConfig struct {
 	InitialDelay    time.Duration `json:"initial_delay"`
 	BackoffFactor   float64       `json:"backoff_factor"`
 	MaxDelay        time.Duration `json:"max_delay"`
+	MaxTotalDelay   time.Duration `json:"max_total_delay"`
+	JitterFactor    float64       `json:"jitter_factor"`
 	RetryableErrors []string      `json:"retryable_errors"`
+
+	// OnRetry, when set, is called before waiting for the next attempt.
+	OnRetry func(attempt int, err error, delay time.Duration) `json:"-"`
 }
 
 func NewDefaultRetryConfig() RetryConfig {
@@ -114,6 +125,8 @@ func NewDefaultRetryConfig() RetryConfig {
 		InitialDelay:  1 * time.Second,
 		BackoffFactor: 2.0,
 		MaxDelay:      30 * time.Second,
+		MaxTotalDelay: 2 * time.Minute,
+		JitterFactor:  0.2,
 		RetryableErrors: []string{
 			"timeout",
 			"server_error",
@@ -127,7 +140,18 @@ func (rc RetryConfig) ShouldRetry(err error, attempt int) bool {
 	if attempt >= rc.MaxRetries {
 		return false
 	}
-	return IsRetryableError(err)
+
+	screenshotErr, ok := err.(*ScreenshotError)
+	if !ok || len(rc.RetryableErrors) == 0 {
+		return IsRetryableError(err)
+	}
+
+	for _, errorType := range rc.RetryableErrors {
+		if screenshotErr.ErrorType == errorType {
+			return true
+		}
+	}
+	return false
 }
 
 func (rc RetryConfig) GetDelay(attempt int) time.Duration {
@@ -162,3 +162,11 @@ func TestIsRetryableContextError(t *testing.T) {
 	assert.True(t, apperrors.IsRetryableError(ctxErr))
 }
 
+func TestScreenshotError_Annotation(t *testing.T) {
+	err := &apperrors.ScreenshotError{
+		ErrorType: "timeout",
+		Timestamp: time.Date(2026, 10, 16, 12, 0, 30, 0, time.FixedZone("CEST", 2*60*60)),
+	}
+
+	assert.Equal(t, "timeout (2026-10-16T10:00Z)", err.Annotation())
+}
@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Do runs op until it succeeds, returns an error ShouldRetry rejects, the
+// accumulated backoff would exceed MaxTotalDelay, or ctx is done. The error
+// from the last attempt is returned.
+func (rc RetryConfig) Do(ctx context.Context, op func(attempt int) error) error {
+	var waited time.Duration
+
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := op(attempt)
+		if err == nil {
+			return nil
+		}
+
+		if !rc.ShouldRetry(err, attempt) {
+			return err
+		}
+
+		delay := rc.jitter(rc.GetDelay(attempt))
+		if rc.MaxTotalDelay > 0 && waited+delay > rc.MaxTotalDelay {
+			return err
+		}
+
+		if rc.OnRetry != nil {
+			rc.OnRetry(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (retry aborted: %w)", err, ctx.Err())
+		case <-timer.C:
+		}
+		waited += delay
+	}
+}
+
+func (rc RetryConfig) jitter(delay time.Duration) time.Duration {
+	if rc.JitterFactor <= 0 || delay <= 0 {
+		return delay
+	}
+
+	spread := float64(delay) * rc.JitterFactor
+	return time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+}
@@ -0,0 +1,134 @@
+package errors_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	apperrors "screenshot-tweets/internal/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fastRetryConfig(maxRetries int) apperrors.RetryConfig {
+	config := apperrors.NewDefaultRetryConfig()
+	config.MaxRetries = maxRetries
+	config.InitialDelay = time.Millisecond
+	config.MaxDelay = 5 * time.Millisecond
+	return config
+}
+
+func TestRetryConfig_Do(t *testing.T) {
+	for _, test := range []struct {
+		name             string
+		maxRetries       int
+		failures         int
+		errorType        string
+		expectError      bool
+		expectedAttempts int
+	}{
+		{"succeeds first time", 3, 0, "timeout", false, 1},
+		{"succeeds after retries", 3, 2, "timeout", false, 3},
+		{"exhausts retries", 2, 5, "timeout", true, 3},
+		{"non retryable error", 3, 5, "not_found", true, 1},
+		{"retries disabled", 0, 5, "timeout", true, 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := 0
+			err := fastRetryConfig(test.maxRetries).Do(context.Background(), func(attempt int) error {
+				assert.Equal(t, attempts, attempt)
+				attempts++
+				if attempts <= test.failures {
+					return &apperrors.ScreenshotError{ErrorType: test.errorType}
+				}
+				return nil
+			})
+
+			if test.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedAttempts, attempts)
+		})
+	}
+}
+
+func TestRetryConfig_DoRespectsRetryableErrors(t *testing.T) {
+	config := fastRetryConfig(3)
+	config.RetryableErrors = []string{"not_found"}
+
+	attempts := 0
+	err := config.Do(context.Background(), func(attempt int) error {
+		attempts++
+		return &apperrors.ScreenshotError{ErrorType: "timeout"}
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	attempts = 0
+	err = config.Do(context.Background(), func(attempt int) error {
+		attempts++
+		return &apperrors.ScreenshotError{ErrorType: "not_found"}
+	})
+	require.Error(t, err)
+	assert.Equal(t, 4, attempts)
+}
+
+func TestRetryConfig_DoMaxTotalDelay(t *testing.T) {
+	config := fastRetryConfig(10)
+	config.InitialDelay = 10 * time.Millisecond
+	config.MaxDelay = 10 * time.Millisecond
+	config.JitterFactor = 0
+	config.MaxTotalDelay = 25 * time.Millisecond
+
+	attempts := 0
+	err := config.Do(context.Background(), func(attempt int) error {
+		attempts++
+		return fmt.Errorf("connection timeout")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestRetryConfig_DoContextCancelled(t *testing.T) {
+	config := fastRetryConfig(3)
+	config.InitialDelay = time.Hour
+	config.MaxDelay = time.Hour
+	config.MaxTotalDelay = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	config.OnRetry = func(attempt int, err error, delay time.Duration) {
+		cancel()
+	}
+
+	attempts := 0
+	err := config.Do(ctx, func(attempt int) error {
+		attempts++
+		return fmt.Errorf("connection timeout")
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "connection timeout")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryConfig_DoJitter(t *testing.T) {
+	config := fastRetryConfig(1)
+	config.InitialDelay = 100 * time.Millisecond
+	config.MaxDelay = 100 * time.Millisecond
+	config.JitterFactor = 0.5
+
+	var delay time.Duration
+	config.OnRetry = func(attempt int, err error, d time.Duration) {
+		delay = d
+	}
+
+	_ = config.Do(context.Background(), func(attempt int) error {
+		return fmt.Errorf("connection timeout")
+	})
+	assert.GreaterOrEqual(t, delay, 50*time.Millisecond)
+	assert.LessOrEqual(t, delay, 150*time.Millisecond)
+}
@@ -16,20 +16,54 @@ type DayEntry struct {
 	Screenshot    string `json:"screenshot"`
 	HasScreenshot bool   `json:"has_screenshot"`
 	Error         string `json:"error,omitempty"`
+	Selector      string `json:"selector,omitempty"`
+	FullPage      *bool  `json:"full_page,omitempty"`
+	Highlight     *bool  `json:"highlight,omitempty"`
+	Device        string `json:"device,omitempty"`
+
+	// CaptureOrder lists the capture strategies to try for the entry
+	CaptureOrder []string `json:"capture_order,omitempty"`
+
+	ColorSchemes  []string `json:"color_schemes,omitempty"`
+	ReducedMotion *bool    `json:"reduced_motion,omitempty"`
+	MediaType     string   `json:"media_type,omitempty"`
+
+	// Wait lists the wait steps of the entry, one per Wait line
+	Wait []string `json:"wait,omitempty"`
+
+	// Inject lists the CSS and JavaScript files to inject, relative to the
+	// markdown file, one per Inject line
+	Inject []string `json:"inject,omitempty"`
 }
 
 type MarkdownFile struct {
 	FilePath string     `json:"file_path"`
 	Entries  []DayEntry `json:"entries"`
+	Formats  []string   `json:"formats,omitempty"`
 	content  []string
 }
 
-const screenshotPrefix = "Screen Shot: "
+const (
+	screenshotPrefix      = "Screen Shot: "
+	screenshotErrorPrefix = "Screenshot Error: "
+)
 
 var (
-	dayHeaderRegex  = regexp.MustCompile(`^## Day (\d+)`)
-	urlRegex        = regexp.MustCompile(`^- URL: (https?://.+)$`)
-	screenshotRegex = regexp.MustCompile(`^Screen Shot: (.+)$`)
+	dayHeaderRegex       = regexp.MustCompile(`^## Day (\d+)`)
+	urlRegex             = regexp.MustCompile(`^- URL: (https?://.+)$`)
+	screenshotRegex      = regexp.MustCompile(`^Screen Shot: (.+)$`)
+	screenshotErrorRegex = regexp.MustCompile(`^Screenshot Error: (.+)$`)
+	selectorRegex        = regexp.MustCompile(`^- Selector: (.+)$`)
+	fullPageRegex        = regexp.MustCompile(`(?i)^- Full Page: (yes|no|true|false)$`)
+	highlightRegex       = regexp.MustCompile(`(?i)^- Highlight: (yes|no|true|false)$`)
+	colorSchemeRegex     = regexp.MustCompile(`^- Color Scheme: (.+)$`)
+	reducedMotionRegex   = regexp.MustCompile(`(?i)^- Reduced Motion: (yes|no|true|false)$`)
+	mediaRegex           = regexp.MustCompile(`^- Media: (.+)$`)
+	deviceRegex          = regexp.MustCompile(`^- Device: (.+)$`)
+	captureRegex         = regexp.MustCompile(`^- Capture: (.+)$`)
+	waitRegex            = regexp.MustCompile(`^- Wait: (.+)$`)
+	injectRegex          = regexp.MustCompile(`^- Inject: (.+)$`)
+	formatsRegex         = regexp.MustCompile(`^Formats: (.+)$`)
 )
 
 func ParseMarkdownFile(filePath string) (*MarkdownFile, error) {
@@ -82,6 +116,53 @@ func ParseMarkdownFile(filePath string) (*MarkdownFile, error) {
 				currentEntry.Screenshot = matches[1]
 				currentEntry.HasScreenshot = true
 			}
+
+			if matches := screenshotErrorRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.Error = matches[1]
+			}
+
+			if matches := selectorRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.Selector = strings.TrimSpace(matches[1])
+			}
+
+			if matches := fullPageRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.FullPage = parseSwitch(matches[1])
+			}
+
+			if matches := highlightRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.Highlight = parseSwitch(matches[1])
+			}
+
+			if matches := deviceRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.Device = strings.TrimSpace(matches[1])
+			}
+
+			if matches := captureRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.CaptureOrder = splitList(matches[1])
+			}
+
+			if matches := waitRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.Wait = append(currentEntry.Wait, strings.TrimSpace(matches[1]))
+			}
+
+			if matches := injectRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.Inject = append(currentEntry.Inject, strings.TrimSpace(matches[1]))
+			}
+
+			if matches := colorSchemeRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.ColorSchemes = splitList(matches[1])
+			}
+
+			if matches := reducedMotionRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.ReducedMotion = parseSwitch(matches[1])
+			}
+
+			if matches := mediaRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+				currentEntry.MediaType = strings.TrimSpace(matches[1])
+			}
+		} else if matches := formatsRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+			// A "Formats:" line before the first day applies to the whole file
+			mf.Formats = splitList(matches[1])
 		}
 	}
 
@@ -97,6 +178,21 @@ func ParseMarkdownFile(filePath string) (*MarkdownFile, error) {
 	return mf, nil
 }
 
+func parseSwitch(value string) *bool {
+	on := strings.EqualFold(value, "yes") || strings.EqualFold(value, "true")
+	return &on
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func (mf *MarkdownFile) UpdateScreenshotReference(day int, filename string) error {
 	for i, entry := range mf.Entries {
 		if entry.Day == day {
@@ -104,43 +200,104 @@ func (mf *MarkdownFile) UpdateScreenshotReference(day int, filename string) erro
 				return fmt.Errorf("day %d already has a screenshot reference", day)
 			}
 
+			_, end, err := mf.sectionBounds(day)
+			if err != nil {
+				return err
+			}
+
 			mf.Entries[i].Screenshot = filename
 			mf.Entries[i].HasScreenshot = true
+			mf.insertLine(end, screenshotPrefix+filename)
 
-			dayHeaderPattern := fmt.Sprintf("## Day %d", day)
-			var insertIndex = -1
+			return nil
+		}
+	}
 
-			for j, line := range mf.content {
-				if strings.Contains(line, dayHeaderPattern) {
-					for k := j + 1; k < len(mf.content); k++ {
-						if strings.HasPrefix(mf.content[k], "## Day") {
-							insertIndex = k - 1
-							break
-						}
-					}
-					if insertIndex == -1 {
-						insertIndex = len(mf.content) - 1
-					}
-					break
-				}
-			}
+	return fmt.Errorf("day %d not found", day)
+}
 
-			if insertIndex == -1 {
-				return fmt.Errorf("day %d not found in markdown content", day)
-			}
+// SetScreenshotError writes a "Screenshot Error:" line for day, replacing
+// any earlier one.
+func (mf *MarkdownFile) SetScreenshotError(day int, annotation string) error {
+	index, err := mf.entryIndex(day)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := mf.sectionBounds(day)
+	if err != nil {
+		return err
+	}
 
-			screenshotLine := screenshotPrefix + filename
-			newContent := make([]string, len(mf.content)+1)
-			copy(newContent[:insertIndex+1], mf.content[:insertIndex+1])
-			newContent[insertIndex+1] = screenshotLine
-			copy(newContent[insertIndex+2:], mf.content[insertIndex+1:])
-			mf.content = newContent
+	mf.Entries[index].Error = annotation
+	errorLine := screenshotErrorPrefix + annotation
 
+	for j := start + 1; j < end; j++ {
+		if screenshotErrorRegex.MatchString(strings.TrimSpace(mf.content[j])) {
+			mf.content[j] = errorLine
 			return nil
 		}
 	}
 
-	return fmt.Errorf("day %d not found", day)
+	mf.insertLine(end, errorLine)
+	return nil
+}
+
+// ClearScreenshotError removes the "Screenshot Error:" line for day, if any.
+func (mf *MarkdownFile) ClearScreenshotError(day int) error {
+	index, err := mf.entryIndex(day)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := mf.sectionBounds(day)
+	if err != nil {
+		return err
+	}
+
+	mf.Entries[index].Error = ""
+
+	for j := start + 1; j < end; j++ {
+		if screenshotErrorRegex.MatchString(strings.TrimSpace(mf.content[j])) {
+			mf.content = append(mf.content[:j], mf.content[j+1:]...)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (mf *MarkdownFile) entryIndex(day int) (int, error) {
+	for i, entry := range mf.Entries {
+		if entry.Day == day {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("day %d not found", day)
+}
+
+func (mf *MarkdownFile) sectionBounds(day int) (int, int, error) {
+	for j, line := range mf.content {
+		matches := dayHeaderRegex.FindStringSubmatch(line)
+		if matches == nil || matches[1] != strconv.Itoa(day) {
+			continue
+		}
+
+		for k := j + 1; k < len(mf.content); k++ {
+			if strings.HasPrefix(mf.content[k], "## Day") {
+				return j, k, nil
+			}
+		}
+		return j, len(mf.content), nil
+	}
+
+	return -1, -1, fmt.Errorf("day %d not found in markdown content", day)
+}
+
+func (mf *MarkdownFile) insertLine(index int, line string) {
+	mf.content = append(mf.content, "")
+	copy(mf.content[index+1:], mf.content[index:])
+	mf.content[index] = line
 }
 
 func (mf *MarkdownFile) WriteMarkdownFile() error {
@@ -192,13 +349,24 @@ func (mf *MarkdownFile) WriteMarkdownFile() error {
 	return nil
 }
 
+// GetEntriesWithoutScreenshots returns entries with a URL that have neither
+// a screenshot nor a recorded capture error.
 func (mf *MarkdownFile) GetEntriesWithoutScreenshots() []DayEntry {
 	var entries []DayEntry
 	for _, entry := range mf.Entries {
-		if !entry.HasScreenshot && entry.URL != "" {
+		if !entry.HasScreenshot && entry.URL != "" && entry.Error == "" {
 			entries = append(entries, entry)
 		}
 	}
 	return entries
 }
 
+func (mf *MarkdownFile) GetEntriesWithErrors() []DayEntry {
+	var entries []DayEntry
+	for _, entry := range mf.Entries {
+		if !entry.HasScreenshot && entry.URL != "" && entry.Error != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -206,3 +206,212 @@ Entry with URL but no screenshot.
 	assert.Equal(t, "https://example.com/4", entries[1].URL)
 }
 
+func TestParseMarkdownFileScreenshotError(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.md")
+
+	content := `## Day 1
+Entry that failed before.
+- URL: https://example.com/1
+Screenshot Error: timeout (2026-10-16T10:00Z)
+
+## Day 2
+Entry without errors.
+- URL: https://example.com/2`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	require.NoError(t, err)
+
+	mf, err := markdown.ParseMarkdownFile(testFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, "timeout (2026-10-16T10:00Z)", mf.Entries[0].Error)
+	assert.Empty(t, mf.Entries[1].Error)
+
+	withoutScreenshots := mf.GetEntriesWithoutScreenshots()
+	require.Len(t, withoutScreenshots, 1)
+	assert.Equal(t, 2, withoutScreenshots[0].Day)
+
+	withErrors := mf.GetEntriesWithErrors()
+	require.Len(t, withErrors, 1)
+	assert.Equal(t, 1, withErrors[0].Day)
+}
+
+func TestSetAndClearScreenshotError(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.md")
+
+	content := `## Day 1
+First entry.
+- URL: https://example.com/1
+## Day 2
+Second entry.
+- URL: https://example.com/2`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	require.NoError(t, err)
+
+	mf, err := markdown.ParseMarkdownFile(testFile)
+	require.NoError(t, err)
+
+	require.NoError(t, mf.SetScreenshotError(1, "timeout (2026-10-16T10:00Z)"))
+	require.NoError(t, mf.SetScreenshotError(1, "server_error (2026-10-16T11:00Z)"))
+	assert.Equal(t, "server_error (2026-10-16T11:00Z)", mf.Entries[0].Error)
+	require.NoError(t, mf.WriteMarkdownFile())
+
+	updated, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, `## Day 1
+First entry.
+- URL: https://example.com/1
+Screenshot Error: server_error (2026-10-16T11:00Z)
+## Day 2
+Second entry.
+- URL: https://example.com/2
+`, string(updated))
+
+	mf, err = markdown.ParseMarkdownFile(testFile)
+	require.NoError(t, err)
+	assert.Equal(t, "server_error (2026-10-16T11:00Z)", mf.Entries[0].Error)
+
+	require.NoError(t, mf.ClearScreenshotError(1))
+	require.NoError(t, mf.UpdateScreenshotReference(1, "day-1-screenshot.png"))
+	assert.Empty(t, mf.Entries[0].Error)
+	require.NoError(t, mf.WriteMarkdownFile())
+
+	updated, err = os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.NotContains(t, string(updated), "Screenshot Error:")
+	assert.Contains(t, string(updated), "- URL: https://example.com/1\nScreen Shot: day-1-screenshot.png\n## Day 2")
+
+	err = mf.SetScreenshotError(999, "timeout (2026-10-16T10:00Z)")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "day 999 not found")
+}
+
+func TestParseMarkdownFileFormats(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "test.md")
+
+	content := `# Daily Tweet Log
+Formats: twitter, bluesky
+
+## Day 1
+Formats: ignored inside an entry
+- URL: https://example.com/1`
+
+	err := os.WriteFile(testFile, []byte(content), 0644)
+	require.NoError(t, err)
+
+	mf, err := markdown.ParseMarkdownFile(testFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"twitter", "bluesky"}, mf.Formats)
+	require.Len(t, mf.Entries, 1)
+	assert.Equal(t, "https://example.com/1", mf.Entries[0].URL)
+}
+
+func TestParseMarkdownFileDirectives(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		directives string
+		check      func(t *testing.T, without, with markdown.DayEntry)
+	}{
+		{
+			name:       "selector",
+			directives: "- Selector: article .content",
+			check: func(t *testing.T, without, with markdown.DayEntry) {
+				assert.Empty(t, without.Selector)
+				assert.Equal(t, "article .content", with.Selector)
+			},
+		},
+		{
+			name:       "full page",
+			directives: "- Full Page: yes",
+			check: func(t *testing.T, without, with markdown.DayEntry) {
+				assert.Nil(t, without.FullPage)
+				require.NotNil(t, with.FullPage)
+				assert.True(t, *with.FullPage)
+			},
+		},
+		{
+			name:       "media emulation",
+			directives: "- Color Scheme: light, dark\n- Reduced Motion: yes\n- Media: print",
+			check: func(t *testing.T, without, with markdown.DayEntry) {
+				assert.Nil(t, without.ColorSchemes)
+				assert.Nil(t, without.ReducedMotion)
+				assert.Empty(t, without.MediaType)
+				assert.Equal(t, []string{"light", "dark"}, with.ColorSchemes)
+				require.NotNil(t, with.ReducedMotion)
+				assert.True(t, *with.ReducedMotion)
+				assert.Equal(t, "print", with.MediaType)
+			},
+		},
+		{
+			name:       "device",
+			directives: "- Device: pixel-8",
+			check: func(t *testing.T, without, with markdown.DayEntry) {
+				assert.Empty(t, without.Device)
+				assert.Equal(t, "pixel-8", with.Device)
+			},
+		},
+		{
+			name:       "wait steps",
+			directives: "- Wait: selector:#app\n- Wait: delay:500ms",
+			check: func(t *testing.T, without, with markdown.DayEntry) {
+				assert.Nil(t, without.Wait)
+				assert.Equal(t, []string{"selector:#app", "delay:500ms"}, with.Wait)
+			},
+		},
+		{
+			name:       "injections",
+			directives: "- Inject: styles/hide-header.css\n- Inject: scripts/expand.js",
+			check: func(t *testing.T, without, with markdown.DayEntry) {
+				assert.Nil(t, without.Inject)
+				assert.Equal(t, []string{"styles/hide-header.css", "scripts/expand.js"}, with.Inject)
+			},
+		},
+		{
+			name:       "highlight",
+			directives: "- Highlight: no",
+			check: func(t *testing.T, without, with markdown.DayEntry) {
+				assert.Nil(t, without.Highlight)
+				require.NotNil(t, with.Highlight)
+				assert.False(t, *with.Highlight)
+			},
+		},
+		{
+			name:       "capture order",
+			directives: "- Capture: opengraph, browser",
+			check: func(t *testing.T, without, with markdown.DayEntry) {
+				assert.Nil(t, without.CaptureOrder)
+				assert.Equal(t, []string{"opengraph", "browser"}, with.CaptureOrder)
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "test.md")
+			content := "## Day 1\n- URL: https://example.com/1\n\n## Day 2\n- URL: https://example.com/2\n" + test.directives + "\n"
+			require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+			mf, err := markdown.ParseMarkdownFile(testFile)
+			require.NoError(t, err)
+			require.Len(t, mf.Entries, 2)
+			test.check(t, mf.Entries[0], mf.Entries[1])
+		})
+	}
+}
+
+func TestParseMarkdownFileKeepsInvalidDirectives(t *testing.T) {
+	// Values are validated by the caller, so that all directives are
+	// reported the same way
+	testFile := filepath.Join(t.TempDir(), "test.md")
+	content := "## Day 1\n- URL: https://example.com/1\n- Color Scheme: sepia\n- Media: tv\n"
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	mf, err := markdown.ParseMarkdownFile(testFile)
+	require.NoError(t, err)
+	require.Len(t, mf.Entries, 1)
+	assert.Equal(t, []string{"sepia"}, mf.Entries[0].ColorSchemes)
+	assert.Equal(t, "tv", mf.Entries[0].MediaType)
+}
@@ -0,0 +1,216 @@
+package screenshot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ResourceTypes are the resource types requests can be blocked by. Blocking
+// documents would fail the navigation to the page itself.
+var ResourceTypes = []string{
+	"stylesheet", "image", "media", "font", "script", "texttrack",
+	"xhr", "fetch", "prefetch", "eventsource", "websocket", "manifest", "ping", "other",
+}
+
+// Blocklist decides which requests a capture blocks: requests to a listed
+// domain or one of its subdomains, of a listed resource type, or matching
+// an Adblock-style URL pattern.
+type Blocklist struct {
+	domains  map[string]bool
+	types    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+func NewBlocklist() *Blocklist {
+	return &Blocklist{domains: make(map[string]bool), types: make(map[string]bool)}
+}
+
+// LoadBlocklist reads a blocklist file in hosts or Adblock syntax.
+func LoadBlocklist(path string) (*Blocklist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blocklist %s: %w", path, err)
+	}
+	defer file.Close()
+
+	b := NewBlocklist()
+	if err := b.Read(file); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Read adds the rules in r, one per line. Both hosts files
+// ("0.0.0.0 ads.example.com") and Adblock filters ("||ads.example.com^",
+// "/banner/*") are understood. Comments, element hiding rules, exceptions
+// and rules with options are skipped.
+func (b *Blocklist) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "", strings.HasPrefix(line, "!"), strings.HasPrefix(line, "#"), strings.HasPrefix(line, "["):
+			continue
+		case strings.HasPrefix(line, "@@"), strings.Contains(line, "##"), strings.Contains(line, "#@#"), strings.Contains(line, "#?#"):
+			continue
+		case strings.Contains(line, "$") && !isRegexpPattern(line):
+			continue
+		}
+
+		if fields := strings.Fields(line); len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+			for _, host := range fields[1:] {
+				if strings.HasPrefix(host, "#") {
+					break
+				}
+				// Skip local names like localhost and ip6-loopback
+				if strings.Contains(host, ".") && net.ParseIP(host) == nil {
+					b.AddDomain(host)
+				}
+			}
+			continue
+		}
+
+		if domain, ok := adblockDomain(line); ok {
+			b.AddDomain(domain)
+			continue
+		}
+
+		if err := b.AddPattern(line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+var domainRegex = regexp.MustCompile(`^[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)+$`)
+
+// adblockDomain returns the domain of filters that block a whole domain,
+// "||ads.example.com^" or a bare "ads.example.com".
+func adblockDomain(filter string) (string, bool) {
+	if strings.HasPrefix(filter, "||") && strings.HasSuffix(filter, "^") {
+		filter = strings.TrimSuffix(strings.TrimPrefix(filter, "||"), "^")
+	}
+	return filter, domainRegex.MatchString(filter)
+}
+
+func (b *Blocklist) AddDomain(domain string) {
+	b.domains[strings.ToLower(strings.TrimSuffix(domain, "."))] = true
+}
+
+func (b *Blocklist) AddResourceType(resourceType string) error {
+	if strings.EqualFold(resourceType, "document") {
+		return fmt.Errorf("resource type %q cannot be blocked, it is the page itself", resourceType)
+	}
+	for _, known := range ResourceTypes {
+		if strings.EqualFold(resourceType, known) {
+			b.types[known] = true
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown resource type %q", resourceType)
+}
+
+// AddPattern adds an Adblock-style URL pattern: "*" matches anything, "^"
+// a separator, "||" anchors at the domain and "|" at the start or end of
+// the URL. Patterns wrapped in slashes are regular expressions.
+func (b *Blocklist) AddPattern(pattern string) error {
+	re, err := patternRegexp(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid URL pattern %q: %w", pattern, err)
+	}
+	b.patterns = append(b.patterns, re)
+	return nil
+}
+
+func patternRegexp(pattern string) (*regexp.Regexp, error) {
+	if isRegexpPattern(pattern) {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+
+	var expr strings.Builder
+	expr.WriteString("(?i)")
+	switch {
+	case strings.HasPrefix(pattern, "||"):
+		expr.WriteString(`^[a-z][a-z0-9+.-]*://([^/?#]*\.)?`)
+		pattern = pattern[2:]
+	case strings.HasPrefix(pattern, "|"):
+		expr.WriteString("^")
+		pattern = pattern[1:]
+	}
+
+	anchorEnd := strings.HasSuffix(pattern, "|")
+	pattern = strings.TrimSuffix(pattern, "|")
+
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '^':
+			expr.WriteString(`(?:[^\w.%-]|$)`)
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	if anchorEnd {
+		expr.WriteString("$")
+	}
+	return regexp.Compile(expr.String())
+}
+
+func isRegexpPattern(pattern string) bool {
+	return len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// Merge adds the rules of other to b.
+func (b *Blocklist) Merge(other *Blocklist) {
+	for domain := range other.domains {
+		b.domains[domain] = true
+	}
+	for resourceType := range other.types {
+		b.types[resourceType] = true
+	}
+	b.patterns = append(b.patterns, other.patterns...)
+}
+
+// Len returns the number of rules.
+func (b *Blocklist) Len() int {
+	return len(b.domains) + len(b.types) + len(b.patterns)
+}
+
+// Blocks reports whether a request for rawURL of resourceType is blocked.
+func (b *Blocklist) Blocks(rawURL, resourceType string) bool {
+	if b.types[strings.ToLower(resourceType)] {
+		return true
+	}
+
+	if u, err := url.Parse(rawURL); err == nil {
+		host := strings.ToLower(u.Hostname())
+		for host != "" {
+			if b.domains[host] {
+				return true
+			}
+			dot := strings.Index(host, ".")
+			if dot < 0 {
+				break
+			}
+			host = host[dot+1:]
+		}
+	}
+
+	for _, pattern := range b.patterns {
+		if pattern.MatchString(rawURL) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package screenshot_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleBlocklist = `# hosts file
+0.0.0.0 ads.example.com tracker.example.net # trailing comment
+127.0.0.1 localhost
+::1 localhost ip6-localhost
+
+[Adblock Plus 2.0]
+! Title: sample
+||doubleclick.net^
+cdn.analytics.io
+/banner/*/ad-
+|https://example.org/popup.js|
+/\/pixel\.(gif|png)$/
+@@||allowed.example.com^
+example.com##.ad-slot
+||video.example.com^$media
+`
+
+func TestBlocklistRead(t *testing.T) {
+	blocklist := screenshot.NewBlocklist()
+	require.NoError(t, blocklist.Read(strings.NewReader(sampleBlocklist)))
+	assert.Equal(t, 7, blocklist.Len())
+
+	for _, test := range []struct {
+		name    string
+		url     string
+		blocked bool
+	}{
+		{"hosts entry", "https://ads.example.com/script.js", true},
+		{"second host on a line", "http://tracker.example.net/t", true},
+		{"subdomain", "https://stats.g.doubleclick.net/collect", true},
+		{"bare domain", "https://cdn.analytics.io/a.js", true},
+		{"parent domain", "https://example.com/", false},
+		{"wildcard pattern", "https://news.example.com/banner/top/ad-1.png", true},
+		{"anchored pattern", "https://example.org/popup.js", true},
+		{"anchored pattern with suffix", "https://example.org/popup.js?v=2", false},
+		{"regular expression", "https://example.org/img/pixel.gif", true},
+		{"exception skipped", "https://allowed.example.com/", false},
+		{"rule with options skipped", "https://video.example.com/clip.mp4", false},
+		{"localhost skipped", "http://localhost:8080/", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.blocked, blocklist.Blocks(test.url, "script"))
+		})
+	}
+}
+
+func TestBlocklistResourceTypes(t *testing.T) {
+	blocklist := screenshot.NewBlocklist()
+	require.NoError(t, blocklist.AddResourceType("Media"))
+	require.NoError(t, blocklist.AddResourceType("font"))
+
+	assert.True(t, blocklist.Blocks("https://example.com/video.mp4", "Media"))
+	assert.True(t, blocklist.Blocks("https://example.com/inter.woff2", "Font"))
+	assert.False(t, blocklist.Blocks("https://example.com/", "Document"))
+
+	err := blocklist.AddResourceType("videos")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown resource type "videos"`)
+
+	err = blocklist.AddResourceType("Document")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `resource type "Document" cannot be blocked`)
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(path, []byte(sampleBlocklist), 0644))
+
+	blocklist, err := screenshot.LoadBlocklist(path)
+	require.NoError(t, err)
+	assert.True(t, blocklist.Blocks("https://ads.example.com/", "script"))
+
+	other := screenshot.NewBlocklist()
+	other.AddDomain("Fonts.Example.com.")
+	blocklist.Merge(other)
+	assert.True(t, blocklist.Blocks("https://fonts.example.com/a.css", "stylesheet"))
+
+	_, err = screenshot.LoadBlocklist(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to open blocklist")
+
+	err = blocklist.AddPattern("/[/")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid URL pattern "/[/"`)
+}
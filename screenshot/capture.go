@@ -11,16 +11,21 @@ import (
 	"time"
 
 	"github.com/go-rod/rod"
-	"github.com/go-rod/rod/lib/launcher"
-	"github.com/go-rod/rod/lib/proto"
 )
 
 const (
 	filePermissions = 0644
 	httpTimeout     = 10 * time.Second
+
+	// DefaultMaxHeight is the height full-page captures are cut off at
+	DefaultMaxHeight = 10000
 )
 
 var (
+	// thumbnailURL is where YouTube thumbnails are downloaded from, by video
+	// ID and quality
+	thumbnailURL = "https://img.youtube.com/vi/%s/%s.jpg"
+
 	youtubeURLRegex   = regexp.MustCompile(`^https?://(www\.)?(youtube\.com/watch\?v=|youtu\.be/|youtube\.com/embed/)`)
 	youtubeIDPatterns = []*regexp.Regexp{
 		regexp.MustCompile(`youtube\.com/watch\?v=([a-zA-Z0-9_-]{11})`),
@@ -35,6 +40,112 @@ type ScreenshotConfig struct {
 	Timeout        time.Duration `json:"timeout"`
 	OutputDir      string        `json:"output_dir"`
 	UserAgent      string        `json:"user_agent"`
+	BrowserPath    string        `json:"browser_path"`
+
+	// Device names the emulated device, if any. DeviceScaleFactor is the
+	// device pixel ratio (0 keeps the browser's), Mobile enables mobile
+	// viewport handling and Touch emulates a touch screen.
+	Device            string  `json:"device,omitempty"`
+	DeviceScaleFactor float64 `json:"device_scale_factor,omitempty"`
+	Mobile            bool    `json:"mobile,omitempty"`
+	Touch             bool    `json:"touch,omitempty"`
+
+	// Scale overrides DeviceScaleFactor when positive, capturing at that
+	// device pixel ratio with the same CSS viewport.
+	Scale float64 `json:"scale,omitempty"`
+
+	// Selector clips the screenshot to the first element it matches, grown
+	// by Padding CSS pixels on every side
+	Selector string `json:"selector,omitempty"`
+	Padding  int    `json:"padding,omitempty"`
+
+	// FullPage captures the whole document instead of the viewport. Captures
+	// are cut off at MaxHeight CSS pixels, unless it is 0.
+	FullPage  bool `json:"full_page,omitempty"`
+	MaxHeight int  `json:"max_height,omitempty"`
+
+	// ColorScheme ("light" or "dark"), ReducedMotion and MediaType ("screen"
+	// or "print") are emulated before the page is loaded. Empty values leave
+	// the browser defaults alone.
+	ColorScheme   string `json:"color_scheme,omitempty"`
+	ReducedMotion bool   `json:"reduced_motion,omitempty"`
+	MediaType     string `json:"media_type,omitempty"`
+
+	// OverlayRules dismiss consent dialogs and other overlays once the page
+	// has loaded. OnOverlay, if set, is called for every dismissal.
+	OverlayRules []OverlayRule       `json:"overlay_rules,omitempty"`
+	OnOverlay    func(OverlayAction) `json:"-"`
+
+	// Blocklist blocks matching requests while the page loads. OnBlocked,
+	// if set, is called with the URL of every blocked request.
+	Blocklist *Blocklist       `json:"-"`
+	OnBlocked func(url string) `json:"-"`
+
+	// Proxy, if set, carries the traffic of the browser and of thumbnail
+	// downloads. Only the session's own config is used to launch Chrome.
+	Proxy *Proxy `json:"-"`
+
+	// Sites add request headers and basic auth credentials to the requests
+	// for matching hosts, in the browser and when downloading thumbnails.
+	Sites SiteRules `json:"-"`
+
+	// Wait lists the steps to wait for once the page has loaded, nil for
+	// the DefaultWaitSteps.
+	Wait []WaitStep `json:"wait,omitempty"`
+
+	// HighlightText highlights the text matched by a text fragment in the
+	// URL, which viewport captures are scrolled to.
+	HighlightText bool `json:"highlight_text,omitempty"`
+
+	// CaptureOrder overrides the order of the registry's capturers for this
+	// capture, and can include capturers left out of that order.
+	CaptureOrder []string `json:"capture_order,omitempty"`
+
+	// OGImageMinWidth and OGImageMinHeight are the smallest preview image
+	// the opengraph capturer accepts, in pixels.
+	OGImageMinWidth  int `json:"og_image_min_width,omitempty"`
+	OGImageMinHeight int `json:"og_image_min_height,omitempty"`
+
+	// PDFPage is the page of linked PDFs to render, unless the URL has a
+	// "#page=N" fragment. 0 renders the first page.
+	PDFPage int `json:"pdf_page,omitempty"`
+
+	// Inject lists the CSS and JavaScript injected into matching pages
+	// before the screenshot. OnInjectError, if set, is called for every
+	// injection that fails, unless FailOnInjectError fails the capture.
+	Inject            []Injection                    `json:"inject,omitempty"`
+	OnInjectError     func(source string, err error) `json:"-"`
+	FailOnInjectError bool                           `json:"fail_on_inject_error,omitempty"`
+
+	// Cookies are set for the domain of each page before it is loaded.
+	Cookies *CookieJar `json:"-"`
+
+	// OnCaptured, if set, is called with the name of the capturer that
+	// wrote the screenshot.
+	OnCaptured func(capturer string) `json:"-"`
+
+	// OnWarning, if set, is called for problems that do not stop the
+	// capture, such as a selector that matches nothing.
+	OnWarning func(err error) `json:"-"`
+}
+
+// warn reports err to OnWarning.
+func (c ScreenshotConfig) warn(err error) {
+	if c.OnWarning != nil {
+		c.OnWarning(err)
+	}
+}
+
+// PixelRatio returns the device pixel ratio screenshots are captured at.
+func (c ScreenshotConfig) PixelRatio() float64 {
+	ratio := c.DeviceScaleFactor
+	if c.Scale > 0 {
+		ratio = c.Scale
+	}
+	if ratio <= 0 {
+		return 1
+	}
+	return ratio
 }
 
 func NewDefaultConfig() ScreenshotConfig {
@@ -43,107 +154,25 @@ func NewDefaultConfig() ScreenshotConfig {
 		ViewportHeight: 600,
 		Timeout:        30 * time.Second,
 		OutputDir:      ".",
+		MaxHeight:      DefaultMaxHeight,
 		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
 	}
 }
 
 func CaptureScreenshot(url, filename string, config ScreenshotConfig) error {
-	// Check if URL is YouTube and try thumbnail extraction first
-	if isYouTubeURL(url) {
-		if videoID, err := extractYouTubeVideoID(url); err == nil {
-			if err := downloadThumbnailWithFallback(videoID, filepath.Join(config.OutputDir, filename)); err == nil {
-				return nil
-			}
-		}
-	}
-
-	// Fall back to regular browser screenshot
-	return captureRegularScreenshot(url, filename, config)
+	return CaptureScreenshotContext(context.Background(), url, filename, config)
 }
 
-func captureRegularScreenshot(url, filename string, config ScreenshotConfig) error {
-	launcher := launcher.New().Headless(true)
-
-	u, err := launcher.Launch()
-	if err != nil {
-		return fmt.Errorf("failed to launch browser: %w", err)
-	}
-
-	browser := rod.New().ControlURL(u)
-	if err := browser.Connect(); err != nil {
-		launcher.Cleanup()
-		return fmt.Errorf("failed to connect to browser: %w", err)
-	}
-	defer func() {
-		browser.Close()
-		launcher.Cleanup()
-	}()
-
-	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
-	defer cancel()
-
-	page, err := browser.Context(ctx).Page(proto.TargetCreateTarget{URL: ""})
-	if err != nil {
-		return fmt.Errorf("failed to create page: %w", err)
-	}
-	defer page.Close()
-
-	if err := page.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
-		Width:  config.ViewportWidth,
-		Height: config.ViewportHeight,
-	}); err != nil {
-		return fmt.Errorf("failed to set viewport: %w", err)
-	}
-
-	if err := page.SetUserAgent(&proto.NetworkSetUserAgentOverride{
-		UserAgent: config.UserAgent,
-	}); err != nil {
-		return fmt.Errorf("failed to set user agent: %w", err)
-	}
-
-	if err := page.Navigate(url); err != nil {
-		return fmt.Errorf("failed to navigate to URL: %w", err)
-	}
-
-	if err := WaitForPageLoad(page); err != nil {
-		// If page load fails, still attempt to take a screenshot
-		// This handles cases where pages timeout but are still partially loaded
-		fmt.Printf("Warning: Page load incomplete (%v), attempting screenshot anyway\n", err)
-	}
-
-	screenshot, err := page.Screenshot(false, &proto.PageCaptureScreenshot{
-		Format: proto.PageCaptureScreenshotFormatPng,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to capture screenshot: %w", err)
-	}
-
-	if err := os.WriteFile(filepath.Join(config.OutputDir, filename), screenshot, filePermissions); err != nil {
-		return fmt.Errorf("failed to write screenshot file: %w", err)
-	}
+func CaptureScreenshotContext(ctx context.Context, url, filename string, config ScreenshotConfig) error {
+	session := NewSession(config)
+	defer session.Close()
 
-	return nil
+	return session.CaptureContext(ctx, url, filename)
 }
 
+// WaitForPageLoad waits for page with the DefaultWaitSteps.
 func WaitForPageLoad(page *rod.Page) error {
-	if err := page.WaitLoad(); err != nil {
-		return fmt.Errorf("failed waiting for DOM load: %w", err)
-	}
-
-	_, err := page.Eval(`() => document.fonts.ready`)
-	if err != nil {
-		return fmt.Errorf("failed waiting for fonts: %w", err)
-	}
-
-	page.WaitRequestIdle(3*time.Second, []string{}, []string{}, []proto.NetworkResourceType{})
-
-	if err := page.WaitStable(1 * time.Second); err != nil {
-		return fmt.Errorf("failed waiting for content stability: %w", err)
-	}
-
-	time.Sleep(2 * time.Second)
-
-	return nil
+	return WaitForPage(page, DefaultWaitSteps)
 }
 
 func GenerateFilename(day int, outputDir string) string {
@@ -155,6 +184,28 @@ func GenerateBaseFilename(day int) string {
 	return fmt.Sprintf("day-%d-screenshot.png", day)
 }
 
+// GenerateVariantFilename names an additional capture of day, such as its
+// dark mode version.
+func GenerateVariantFilename(day int, variant string) string {
+	return fmt.Sprintf("day-%d-screenshot-%s.png", day, variant)
+}
+
+// YouTubeThumbnailCapturer downloads the video thumbnail for YouTube URLs
+// instead of screenshotting the player page.
+type YouTubeThumbnailCapturer struct{}
+
+func (YouTubeThumbnailCapturer) CanHandle(url string) bool {
+	return isYouTubeURL(url)
+}
+
+func (YouTubeThumbnailCapturer) Capture(ctx context.Context, url, dest string, config ScreenshotConfig) error {
+	videoID, err := extractYouTubeVideoID(url)
+	if err != nil {
+		return err
+	}
+	return downloadThumbnailWithFallback(ctx, videoID, dest, config)
+}
+
 func isYouTubeURL(url string) bool {
 	return youtubeURLRegex.MatchString(url)
 }
@@ -170,22 +221,31 @@ func extractYouTubeVideoID(url string) (string, error) {
 	return "", fmt.Errorf("could not extract video ID from URL: %s", url)
 }
 
-func downloadThumbnailWithFallback(videoID, outputPath string) error {
+func downloadThumbnailWithFallback(ctx context.Context, videoID, outputPath string, config ScreenshotConfig) error {
 	qualities := []string{"maxresdefault", "hqdefault", "mqdefault", "default"}
 
 	for _, quality := range qualities {
-		if err := downloadThumbnail(videoID, quality, outputPath); err == nil {
+		if err := downloadThumbnail(ctx, videoID, quality, outputPath, config); err == nil {
 			return nil
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 	}
 
 	return fmt.Errorf("failed to download thumbnail for video ID: %s", videoID)
 }
 
-func downloadThumbnail(videoID, quality, outputPath string) error {
-	client := &http.Client{Timeout: httpTimeout}
+func downloadThumbnail(ctx context.Context, videoID, quality, outputPath string, config ScreenshotConfig) error {
+	client := siteClient(config)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(thumbnailURL, videoID, quality), nil)
+	if err != nil {
+		return err
+	}
+	config.Sites.Apply(req)
 
-	resp, err := client.Get(fmt.Sprintf("https://img.youtube.com/vi/%s/%s.jpg", videoID, quality))
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
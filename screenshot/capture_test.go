@@ -19,6 +19,7 @@ func TestNewDefaultConfig(t *testing.T) {
 	assert.Equal(t, 600, config.ViewportHeight)
 	assert.Equal(t, 30*time.Second, config.Timeout)
 	assert.Equal(t, ".", config.OutputDir)
+	assert.Equal(t, 10000, config.MaxHeight)
 	assert.Contains(t, config.UserAgent, "Chrome")
 }
 
@@ -0,0 +1,168 @@
+package screenshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNotHandled is returned by Capture when a capturer finds out that it
+// cannot handle a URL after all, for example from its content type. The
+// next capturer is tried as if CanHandle had returned false.
+var ErrNotHandled = errors.New("capturer cannot handle URL")
+
+// finalError ends a capture instead of letting the next capturer try.
+type finalError struct{ err error }
+
+func (e finalError) Error() string { return e.err.Error() }
+
+func (e finalError) Unwrap() error { return e.err }
+
+// Final marks err, returned by Capture, as the outcome for the URL: the
+// next capturer is not tried, since its image would be the wrong one.
+func Final(err error) error {
+	if err == nil {
+		return nil
+	}
+	return finalError{err}
+}
+
+// Capturer is a strategy for turning a URL into an image file at dest,
+// following the settings in config where they apply.
+type Capturer interface {
+	CanHandle(url string) bool
+	Capture(ctx context.Context, url, dest string, config ScreenshotConfig) error
+}
+
+// Registry holds named capturers in the order they are tried. The first
+// capturer that can handle a URL and succeeds wins; when one fails the
+// next matching capturer is tried, unless the error is Final.
+type Registry struct {
+	mu        sync.RWMutex
+	capturers map[string]Capturer
+	order     []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{capturers: make(map[string]Capturer)}
+}
+
+// Register adds c under name. New names are appended to the end of the
+// order; registering an existing name replaces it in place.
+func (r *Registry) Register(name string, c Capturer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.capturers[name]; !ok {
+		r.order = append(r.order, name)
+	}
+	r.capturers[name] = c
+}
+
+// SetOrder replaces the order capturers are tried in. Registered capturers
+// that are not listed are disabled.
+func (r *Registry) SetOrder(names ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.validateOrder(names); err != nil {
+		return err
+	}
+
+	r.order = append([]string(nil), names...)
+	return nil
+}
+
+// ValidateOrder checks that names can be used as an order, for SetOrder or
+// as the CaptureOrder of a single capture.
+func (r *Registry) ValidateOrder(names ...string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.validateOrder(names)
+}
+
+func (r *Registry) validateOrder(names []string) error {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := r.capturers[name]; !ok {
+			return fmt.Errorf("unknown capturer %q", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("capturer %q listed more than once", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func (r *Registry) Order() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.order...)
+}
+
+// Match returns the name of the first capturer in order that can handle url.
+func (r *Registry) Match(url string) (string, bool) {
+	for _, name := range r.Order() {
+		if r.get(name).CanHandle(url) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Candidates returns the names of the capturers that can handle url, in the
+// order they are tried: order when given, otherwise the registry's. Unknown
+// names in order are skipped.
+func (r *Registry) Candidates(url string, order ...string) []string {
+	if len(order) == 0 {
+		order = r.Order()
+	}
+
+	var names []string
+	for _, name := range order {
+		if c := r.get(name); c != nil && c.CanHandle(url) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Capture tries the capturers that can handle url in order, the CaptureOrder
+// of config when it is set.
+func (r *Registry) Capture(ctx context.Context, url, dest string, config ScreenshotConfig) error {
+	var lastErr error
+
+	for _, name := range r.Candidates(url, config.CaptureOrder...) {
+		c := r.get(name)
+		if err := c.Capture(ctx, url, dest, config); err != nil {
+			if errors.Is(err, ErrNotHandled) {
+				continue
+			}
+			lastErr = err
+			if ctx.Err() != nil || errors.As(err, new(finalError)) {
+				return err
+			}
+			continue
+		}
+		if config.OnCaptured != nil {
+			config.OnCaptured(name)
+		}
+		return nil
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("no capturer can handle URL: %s", url)
+	}
+	return lastErr
+}
+
+func (r *Registry) get(name string) Capturer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.capturers[name]
+}
@@ -0,0 +1,240 @@
+package screenshot_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCapturer struct {
+	name   string
+	prefix string
+	err    error
+	calls  *[]string
+}
+
+func (f fakeCapturer) CanHandle(url string) bool {
+	return strings.HasPrefix(url, f.prefix)
+}
+
+func (f fakeCapturer) Capture(ctx context.Context, url, dest string, config screenshot.ScreenshotConfig) error {
+	*f.calls = append(*f.calls, f.name)
+	if f.err != nil {
+		return f.err
+	}
+	return os.WriteFile(dest, []byte(f.name), 0644)
+}
+
+func TestRegistryCapture(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		order         []string
+		configOrder   []string
+		url           string
+		expectedCalls []string
+		errorContains string
+	}{
+		{
+			name:          "first matching capturer wins",
+			url:           "https://example.com/image.png",
+			expectedCalls: []string{"image"},
+		},
+		{
+			name:          "skips capturers that cannot handle the URL",
+			url:           "https://example.com/page",
+			expectedCalls: []string{"generic"},
+		},
+		{
+			name:          "falls through on failure",
+			url:           "https://broken.example.com/page",
+			expectedCalls: []string{"broken", "generic"},
+		},
+		{
+			name:          "custom order",
+			order:         []string{"generic", "image"},
+			url:           "https://example.com/image.png",
+			expectedCalls: []string{"generic"},
+		},
+		{
+			name:          "capture order of the config",
+			configOrder:   []string{"generic"},
+			url:           "https://example.com/image.png",
+			expectedCalls: []string{"generic"},
+		},
+		{
+			name:          "capture order can use disabled capturers",
+			order:         []string{"image"},
+			configOrder:   []string{"broken", "generic"},
+			url:           "https://broken.example.com/page",
+			expectedCalls: []string{"broken", "generic"},
+		},
+		{
+			name:          "no capturer can handle",
+			order:         []string{"image"},
+			url:           "https://example.com/page",
+			errorContains: "no capturer can handle URL",
+		},
+		{
+			name:          "skips capturers that decline the URL",
+			order:         []string{"declining", "generic"},
+			url:           "https://declined.example.com/page",
+			expectedCalls: []string{"declining", "generic"},
+		},
+		{
+			name:          "declining is not an error",
+			order:         []string{"declining"},
+			url:           "https://declined.example.com/page",
+			expectedCalls: []string{"declining"},
+			errorContains: "no capturer can handle URL",
+		},
+		{
+			name:          "final errors do not fall through",
+			order:         []string{"final", "generic"},
+			url:           "https://final.example.com/page",
+			expectedCalls: []string{"final"},
+			errorContains: "final capturer",
+		},
+		{
+			name:          "returns last error",
+			order:         []string{"broken"},
+			url:           "https://broken.example.com/page",
+			expectedCalls: []string{"broken"},
+			errorContains: "broken capturer",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+			registry := screenshot.NewRegistry()
+			registry.Register("image", fakeCapturer{name: "image", prefix: "https://example.com/image", calls: &calls})
+			registry.Register("broken", fakeCapturer{name: "broken", prefix: "https://broken.", err: fmt.Errorf("broken capturer"), calls: &calls})
+			registry.Register("declining", fakeCapturer{name: "declining", prefix: "https://declined.", err: screenshot.ErrNotHandled, calls: &calls})
+			registry.Register("final", fakeCapturer{name: "final", prefix: "https://final.", err: screenshot.Final(fmt.Errorf("final capturer")), calls: &calls})
+			registry.Register("generic", fakeCapturer{name: "generic", prefix: "https://", calls: &calls})
+
+			if test.order != nil {
+				require.NoError(t, registry.SetOrder(test.order...))
+			}
+
+			config := screenshot.NewDefaultConfig()
+			config.CaptureOrder = test.configOrder
+			err := registry.Capture(context.Background(), test.url, filepath.Join(t.TempDir(), "out.png"), config)
+			if test.errorContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errorContains)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedCalls, calls)
+		})
+	}
+}
+
+func TestRegistrySetOrder(t *testing.T) {
+	var calls []string
+	registry := screenshot.NewRegistry()
+	registry.Register("a", fakeCapturer{name: "a", calls: &calls})
+	registry.Register("b", fakeCapturer{name: "b", calls: &calls})
+	assert.Equal(t, []string{"a", "b"}, registry.Order())
+
+	require.NoError(t, registry.SetOrder("b", "a"))
+	assert.Equal(t, []string{"b", "a"}, registry.Order())
+
+	err := registry.SetOrder("a", "missing")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown capturer "missing"`)
+
+	err = registry.SetOrder("a", "a")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "listed more than once")
+	assert.Equal(t, []string{"b", "a"}, registry.Order())
+
+	assert.NoError(t, registry.ValidateOrder("a"))
+	assert.NoError(t, registry.ValidateOrder())
+	assert.EqualError(t, registry.ValidateOrder("c"), `unknown capturer "c"`)
+}
+
+func TestSessionDefaultCapturers(t *testing.T) {
+	session := screenshot.NewSession(screenshot.NewDefaultConfig())
+	defer session.Close()
+
+	assert.Equal(t, []string{"youtube", "file", "browser"}, session.Registry().Order())
+
+	name, ok := session.Registry().Match("https://youtu.be/Kf5-HWJPTIE?si=01AaOhARAG9tfHFp")
+	assert.True(t, ok)
+	assert.Equal(t, "youtube", name)
+
+	name, ok = session.Registry().Match("https://go.dev/blog/go1.21")
+	assert.True(t, ok)
+	assert.Equal(t, "browser", name)
+	assert.Equal(t, []string{"file", "browser"}, session.Registry().Candidates("https://go.dev/doc/go1.21.pdf"))
+	assert.Equal(t, []string{"opengraph", "browser"}, session.Registry().Candidates("https://go.dev/blog/go1.21", "opengraph", "browser"))
+	assert.NoError(t, session.Registry().ValidateOrder("youtube", "opengraph", "browser"))
+
+	_, ok = session.Registry().Match("invalid-url")
+	assert.False(t, ok)
+}
+
+type configCapturer struct {
+	configs *[]screenshot.ScreenshotConfig
+}
+
+func (c configCapturer) CanHandle(url string) bool {
+	return true
+}
+
+func (c configCapturer) Capture(ctx context.Context, url, dest string, config screenshot.ScreenshotConfig) error {
+	*c.configs = append(*c.configs, config)
+	return os.WriteFile(dest, nil, 0644)
+}
+
+func TestSessionCaptureWithConfig(t *testing.T) {
+	config := screenshot.NewDefaultConfig()
+	config.OutputDir = t.TempDir()
+
+	session := screenshot.NewSession(config)
+	defer session.Close()
+
+	var configs []screenshot.ScreenshotConfig
+	session.Registry().Register("config", configCapturer{configs: &configs})
+	require.NoError(t, session.Registry().SetOrder("config"))
+
+	entryConfig := session.Config()
+	entryConfig.Selector = "article"
+	entryConfig.Padding = 8
+
+	require.NoError(t, session.Capture("https://example.com/1", "one.png"))
+	require.NoError(t, session.CaptureWithConfig(context.Background(), "https://example.com/2", "two.png", entryConfig))
+
+	require.Len(t, configs, 2)
+	assert.Equal(t, config, configs[0])
+	assert.Equal(t, "article", configs[1].Selector)
+	assert.Equal(t, 8, configs[1].Padding)
+	assert.FileExists(t, filepath.Join(config.OutputDir, "two.png"))
+}
+
+func TestSessionCustomCapturer(t *testing.T) {
+	config := screenshot.NewDefaultConfig()
+	config.OutputDir = t.TempDir()
+
+	session := screenshot.NewSession(config)
+	defer session.Close()
+
+	var calls []string
+	session.Registry().Register("pdf", fakeCapturer{name: "pdf", prefix: "https://example.com/doc.pdf", calls: &calls})
+	require.NoError(t, session.Registry().SetOrder("pdf", "youtube", "browser"))
+
+	require.NoError(t, session.Capture("https://example.com/doc.pdf", "day-1-screenshot.png"))
+	assert.Equal(t, []string{"pdf"}, calls)
+
+	data, err := os.ReadFile(filepath.Join(config.OutputDir, "day-1-screenshot.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "pdf", string(data))
+}
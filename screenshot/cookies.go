@@ -0,0 +1,265 @@
+package screenshot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Cookie is a cookie imported from a cookie file. HostOnly cookies are sent
+// to Domain only, others to its subdomains as well.
+type Cookie struct {
+	Name     string
+	Value    string
+	Domain   string
+	Path     string
+	HostOnly bool
+	Secure   bool
+	HTTPOnly bool
+	SameSite string
+	Expires  time.Time
+}
+
+// String describes the cookie without its value, which must never end up
+// in logs or error messages.
+func (c Cookie) String() string {
+	return fmt.Sprintf("%s (%s)", c.Name, c.Domain)
+}
+
+// matches reports whether the cookie is sent to host.
+func (c Cookie) matches(host string) bool {
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	if host == domain {
+		return true
+	}
+	return !c.HostOnly && strings.HasSuffix(host, "."+domain)
+}
+
+// CookieJar holds the cookies of a cookie file, handed to each page for the
+// domain it is about to load.
+type CookieJar struct {
+	cookies []Cookie
+}
+
+// LoadCookieJar reads a Netscape cookies.txt file or a JSON cookie file, as
+// exported by browser extensions or as a Playwright storage state.
+func LoadCookieJar(path string) (*CookieJar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookie file %s: %w", path, err)
+	}
+
+	var cookies []Cookie
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		cookies, err = parseJSONCookies(trimmed)
+	} else {
+		cookies, err = parseNetscapeCookies(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid cookie file %s: %w", path, err)
+	}
+
+	return &CookieJar{cookies: cookies}, nil
+}
+
+// parseNetscapeCookies parses the tab-separated cookies.txt format: domain,
+// include subdomains, path, secure, expiry, name and value.
+func parseNetscapeCookies(data []byte) ([]Cookie, error) {
+	var cookies []Cookie
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", number, len(fields))
+		}
+
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", number, fields[4])
+		}
+
+		cookie := Cookie{
+			Domain:   fields[0],
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HTTPOnly: httpOnly,
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+		if expiry > 0 {
+			cookie.Expires = time.Unix(expiry, 0)
+		}
+		if cookie.Name == "" || cookie.Domain == "" {
+			return nil, fmt.Errorf("line %d: cookie needs a name and a domain", number)
+		}
+
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies, scanner.Err()
+}
+
+type jsonCookie struct {
+	Name           string  `json:"name"`
+	Value          string  `json:"value"`
+	Domain         string  `json:"domain"`
+	Path           string  `json:"path"`
+	HostOnly       *bool   `json:"hostOnly"`
+	Secure         bool    `json:"secure"`
+	HTTPOnly       bool    `json:"httpOnly"`
+	SameSite       string  `json:"sameSite"`
+	Expires        float64 `json:"expires"`
+	ExpirationDate float64 `json:"expirationDate"`
+}
+
+// parseJSONCookies parses a list of cookies or an object with a "cookies"
+// list. Expiry is read from "expires" or "expirationDate", in seconds.
+// Without a "hostOnly" key, as in Playwright storage state, cookies are
+// host-only unless their domain starts with a dot.
+func parseJSONCookies(data []byte) ([]Cookie, error) {
+	var list []jsonCookie
+	if data[0] == '{' {
+		var state struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, err
+		}
+		list = state.Cookies
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	cookies := make([]Cookie, 0, len(list))
+	for i, c := range list {
+		if c.Name == "" || c.Domain == "" {
+			return nil, fmt.Errorf("cookie %d needs a name and a domain", i+1)
+		}
+
+		hostOnly := !strings.HasPrefix(c.Domain, ".")
+		if c.HostOnly != nil {
+			hostOnly = *c.HostOnly
+		}
+
+		cookie := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			HostOnly: hostOnly,
+			Secure:   c.Secure,
+			HTTPOnly: c.HTTPOnly,
+			SameSite: c.SameSite,
+		}
+		if expires := max(c.Expires, c.ExpirationDate); expires > 0 {
+			cookie.Expires = time.Unix(int64(expires), 0)
+		}
+
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies, nil
+}
+
+// Len returns the number of cookies.
+func (j *CookieJar) Len() int {
+	return len(j.cookies)
+}
+
+// Unexpired returns the cookies that have not expired.
+func (j *CookieJar) Unexpired() []Cookie {
+	var cookies []Cookie
+	for _, cookie := range j.cookies {
+		if cookie.Expires.IsZero() || cookie.Expires.After(time.Now()) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	return cookies
+}
+
+// ForURL returns the unexpired cookies for the host of rawURL.
+func (j *CookieJar) ForURL(rawURL string) []Cookie {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+
+	var cookies []Cookie
+	for _, cookie := range j.Unexpired() {
+		if cookie.matches(host) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	return cookies
+}
+
+// setCookies sets cookies in the browser context of page. Host-only cookies
+// are set by URL so they are not shared with subdomains.
+func setCookies(page *rod.Page, cookies []Cookie) error {
+	params := make([]*proto.NetworkCookieParam, len(cookies))
+	for i, cookie := range cookies {
+		param := &proto.NetworkCookieParam{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HTTPOnly: cookie.HTTPOnly,
+			SameSite: sameSite(cookie.SameSite),
+		}
+		if !cookie.Expires.IsZero() {
+			param.Expires = proto.TimeSinceEpoch(cookie.Expires.Unix())
+		}
+
+		if cookie.HostOnly {
+			scheme := "http"
+			if cookie.Secure {
+				scheme = "https"
+			}
+			param.URL = (&url.URL{Scheme: scheme, Host: cookie.Domain, Path: cookie.Path}).String()
+		} else {
+			param.Domain = cookie.Domain
+		}
+
+		params[i] = param
+	}
+
+	if err := (proto.NetworkSetCookies{Cookies: params}).Call(page); err != nil {
+		// The error may echo the cookies, so only their count is reported
+		return fmt.Errorf("browser rejected %d cookies", len(cookies))
+	}
+	return nil
+}
+
+// sameSite maps the SameSite values of cookie exports to Chrome's.
+func sameSite(value string) proto.NetworkCookieSameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return proto.NetworkCookieSameSiteStrict
+	case "lax":
+		return proto.NetworkCookieSameSiteLax
+	case "none", "no_restriction":
+		return proto.NetworkCookieSameSiteNone
+	default:
+		return ""
+	}
+}
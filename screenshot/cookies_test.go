@@ -0,0 +1,155 @@
+package screenshot_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCookieFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cookies")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func cookieNames(cookies []screenshot.Cookie) []string {
+	var names []string
+	for _, cookie := range cookies {
+		names = append(names, cookie.Name)
+	}
+	return names
+}
+
+func TestLoadCookieJarNetscape(t *testing.T) {
+	path := writeCookieFile(t, fmt.Sprintf(`# Netscape HTTP Cookie File
+.medium.com	TRUE	/	TRUE	0	sid	secret-1
+#HttpOnly_dashboard.example.com	FALSE	/	TRUE	%d	session	secret-2
+dashboard.example.com	FALSE	/	FALSE	1000	expired	secret-3
+`, time.Now().Add(time.Hour).Unix()))
+
+	jar, err := screenshot.LoadCookieJar(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, jar.Len())
+
+	assert.Equal(t, []string{"sid"}, cookieNames(jar.ForURL("https://medium.com/@someone/post")))
+	assert.Equal(t, []string{"sid"}, cookieNames(jar.ForURL("https://blog.medium.com/post")))
+	assert.Empty(t, jar.ForURL("https://notmedium.com/"))
+
+	cookies := jar.ForURL("https://dashboard.example.com/reports")
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "session", cookies[0].Name)
+	assert.True(t, cookies[0].HostOnly)
+	assert.True(t, cookies[0].HTTPOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Empty(t, jar.ForURL("https://eu.dashboard.example.com/"))
+}
+
+func TestLoadCookieJarJSON(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+	}{
+		{"extension export", `[
+			{"name": "sid", "value": "secret", "domain": ".medium.com", "path": "/", "secure": true, "sameSite": "no_restriction", "expirationDate": 4102444800.5},
+			{"name": "uid", "value": "secret", "domain": "medium.com", "hostOnly": true}
+		]`},
+		{"storage state", `{"cookies": [
+			{"name": "sid", "value": "secret", "domain": ".medium.com", "path": "/", "expires": -1, "httpOnly": true, "secure": true, "sameSite": "Lax"},
+			{"name": "uid", "value": "secret", "domain": "medium.com", "path": "/", "expires": 4102444800, "httpOnly": false, "secure": false, "sameSite": "None"}
+		], "origins": []}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			jar, err := screenshot.LoadCookieJar(writeCookieFile(t, test.content))
+			require.NoError(t, err)
+			assert.Equal(t, 2, jar.Len())
+			assert.Equal(t, []string{"sid", "uid"}, cookieNames(jar.ForURL("https://medium.com/")))
+			assert.Equal(t, []string{"sid"}, cookieNames(jar.ForURL("https://blog.medium.com/")))
+		})
+	}
+}
+
+func TestLoadCookieJarErrors(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		content       string
+		errorContains string
+	}{
+		{"missing fields", "medium.com\tTRUE\t/\tsecret-value\n", "line 1: expected 7 tab-separated fields, got 4"},
+		{"invalid expiry", "medium.com\tTRUE\t/\tTRUE\tsoon\tsid\tsecret-value\n", `line 1: invalid expiry "soon"`},
+		{"missing name", `[{"value": "secret-value", "domain": "medium.com"}]`, "cookie 1 needs a name and a domain"},
+		{"invalid json", `[{"name": "sid", "value": 42}]`, "cannot unmarshal"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := screenshot.LoadCookieJar(writeCookieFile(t, test.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errorContains)
+			assert.NotContains(t, err.Error(), "secret-value")
+		})
+	}
+
+	_, err := screenshot.LoadCookieJar(filepath.Join(t.TempDir(), "missing.txt"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read cookie file")
+}
+
+func TestCookieStringHidesValue(t *testing.T) {
+	cookie := screenshot.Cookie{Name: "sid", Value: "secret-value", Domain: ".medium.com"}
+	assert.Equal(t, "sid (.medium.com)", fmt.Sprint(cookie))
+	assert.NotContains(t, fmt.Sprintf("%v", []screenshot.Cookie{cookie}), "secret-value")
+}
+
+func TestBrowserCookiesFollowRedirects(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping screenshot test in short mode")
+	}
+
+	var mu sync.Mutex
+	var received []string
+	dashboard := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cookie, err := r.Cookie("session"); err == nil {
+			received = append(received, cookie.Value)
+		}
+		fmt.Fprint(w, "<html><body>Dashboard</body></html>")
+	}))
+	t.Cleanup(dashboard.Close)
+	dashboardURL := strings.Replace(dashboard.URL, "127.0.0.1", "localhost", 1)
+
+	// The entry URL is on another host than the session cookie
+	login := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, dashboardURL+"/reports", http.StatusFound)
+	}))
+	t.Cleanup(login.Close)
+
+	config := screenshot.NewDefaultConfig()
+	config.OutputDir = t.TempDir()
+	config.CaptureOrder = []string{"browser"}
+	config.Wait = []screenshot.WaitStep{}
+	var err error
+	config.Cookies, err = screenshot.LoadCookieJar(writeCookieFile(t, `[{"name": "session", "value": "abc", "domain": "localhost", "path": "/"}]`))
+	require.NoError(t, err)
+
+	session := screenshot.NewSession(config)
+	defer session.Close()
+	if err := session.Capture(login.URL+"/dashboard", "day-1-screenshot.png"); err != nil {
+		t.Skipf("browser unavailable: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, received, "abc")
+}
@@ -0,0 +1,91 @@
+package screenshot
+
+import (
+	"fmt"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+const (
+	iosUserAgent     = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	ipadosUserAgent  = "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	androidUserAgent = "Mozilla/5.0 (Linux; Android 14; %s) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+
+	maxTouchPoints = 5
+)
+
+// Device is an emulated device: its viewport in CSS pixels, device pixel
+// ratio and user agent, and whether it is a mobile device with a touch
+// screen.
+type Device struct {
+	Name        string  `json:"name" yaml:"name"`
+	Width       int     `json:"width" yaml:"width"`
+	Height      int     `json:"height" yaml:"height"`
+	ScaleFactor float64 `json:"scale_factor" yaml:"scale_factor"`
+	UserAgent   string  `json:"user_agent" yaml:"user_agent"`
+	Mobile      bool    `json:"mobile" yaml:"mobile"`
+	Touch       bool    `json:"touch" yaml:"touch"`
+}
+
+// DevicePresets are the built-in devices that can be selected by key.
+var DevicePresets = map[string]Device{
+	"iphone-se":         {Name: "iPhone SE", Width: 375, Height: 667, ScaleFactor: 2, UserAgent: iosUserAgent, Mobile: true, Touch: true},
+	"iphone-15":         {Name: "iPhone 15", Width: 393, Height: 852, ScaleFactor: 3, UserAgent: iosUserAgent, Mobile: true, Touch: true},
+	"iphone-15-pro-max": {Name: "iPhone 15 Pro Max", Width: 430, Height: 932, ScaleFactor: 3, UserAgent: iosUserAgent, Mobile: true, Touch: true},
+	"pixel-8":           {Name: "Pixel 8", Width: 412, Height: 915, ScaleFactor: 2.625, UserAgent: fmt.Sprintf(androidUserAgent, "Pixel 8"), Mobile: true, Touch: true},
+	"galaxy-s23":        {Name: "Galaxy S23", Width: 360, Height: 780, ScaleFactor: 3, UserAgent: fmt.Sprintf(androidUserAgent, "SM-S911B"), Mobile: true, Touch: true},
+	"ipad":              {Name: "iPad", Width: 810, Height: 1080, ScaleFactor: 2, UserAgent: ipadosUserAgent, Mobile: true, Touch: true},
+	"ipad-mini":         {Name: "iPad mini", Width: 744, Height: 1133, ScaleFactor: 2, UserAgent: ipadosUserAgent, Mobile: true, Touch: true},
+	"ipad-pro":          {Name: "iPad Pro 12.9\"", Width: 1024, Height: 1366, ScaleFactor: 2, UserAgent: ipadosUserAgent, Mobile: true, Touch: true},
+}
+
+func (d Device) Validate() error {
+	if d.Width <= 0 || d.Height <= 0 {
+		return fmt.Errorf("dimensions must be positive, got %dx%d", d.Width, d.Height)
+	}
+
+	if d.ScaleFactor < 0 {
+		return fmt.Errorf("scale factor cannot be negative, got %g", d.ScaleFactor)
+	}
+
+	return nil
+}
+
+// Apply returns config emulating d. The user agent of config is kept when d
+// has none.
+func (d Device) Apply(config ScreenshotConfig) ScreenshotConfig {
+	config.Device = d.Name
+	config.ViewportWidth = d.Width
+	config.ViewportHeight = d.Height
+	config.DeviceScaleFactor = d.ScaleFactor
+	config.Mobile = d.Mobile
+	config.Touch = d.Touch
+	if d.UserAgent != "" {
+		config.UserAgent = d.UserAgent
+	}
+	return config
+}
+
+// emulateDevice sets the viewport, device pixel ratio, mobile mode and touch
+// support of page from config.
+func emulateDevice(page *rod.Page, config ScreenshotConfig) error {
+	if err := page.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
+		Width:             config.ViewportWidth,
+		Height:            config.ViewportHeight,
+		DeviceScaleFactor: config.PixelRatio(),
+		Mobile:            config.Mobile,
+	}); err != nil {
+		return err
+	}
+
+	if !config.Touch {
+		return nil
+	}
+
+	touchPoints := maxTouchPoints
+	return proto.EmulationSetTouchEmulationEnabled{
+		Enabled:        true,
+		MaxTouchPoints: &touchPoints,
+	}.Call(page)
+}
@@ -0,0 +1,41 @@
+package screenshot_test
+
+import (
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevicePresets(t *testing.T) {
+	for key, device := range screenshot.DevicePresets {
+		assert.NoError(t, device.Validate(), key)
+		assert.NotEmpty(t, device.Name, key)
+		assert.NotEmpty(t, device.UserAgent, key)
+		assert.True(t, device.Mobile, key)
+		assert.True(t, device.Touch, key)
+	}
+}
+
+func TestDeviceApply(t *testing.T) {
+	config := screenshot.NewDefaultConfig()
+	config.Selector = "article"
+
+	mobile := screenshot.DevicePresets["pixel-8"].Apply(config)
+	assert.Equal(t, "Pixel 8", mobile.Device)
+	assert.Equal(t, 412, mobile.ViewportWidth)
+	assert.Equal(t, 915, mobile.ViewportHeight)
+	assert.Equal(t, 2.625, mobile.DeviceScaleFactor)
+	assert.Contains(t, mobile.UserAgent, "Android")
+	assert.True(t, mobile.Mobile)
+	assert.True(t, mobile.Touch)
+	assert.Equal(t, "article", mobile.Selector)
+
+	custom := screenshot.Device{Name: "Kiosk", Width: 1080, Height: 1920}.Apply(mobile)
+	assert.Equal(t, 1080, custom.ViewportWidth)
+	assert.Zero(t, custom.DeviceScaleFactor)
+	assert.False(t, custom.Mobile)
+	assert.False(t, custom.Touch)
+	assert.Equal(t, mobile.UserAgent, custom.UserAgent)
+}
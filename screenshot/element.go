@@ -0,0 +1,54 @@
+package screenshot
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// elementClip returns the document area covered by the first element
+// matching selector, grown by padding CSS pixels and kept inside the page.
+func elementClip(page *rod.Page, selector string, padding int) (*proto.PageViewport, error) {
+	res, err := page.Eval(`selector => {
+		const el = document.querySelector(selector);
+		if (!el) return null;
+		const rect = el.getBoundingClientRect();
+		const root = document.documentElement;
+		return {
+			x: rect.left + window.scrollX, y: rect.top + window.scrollY, width: rect.width, height: rect.height,
+			pageWidth: Math.max(root.scrollWidth, document.body ? document.body.scrollWidth : 0),
+			pageHeight: Math.max(root.scrollHeight, document.body ? document.body.scrollHeight : 0),
+		};
+	}`, selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query selector %q: %w", selector, err)
+	}
+
+	if res.Value.Nil() {
+		return nil, fmt.Errorf("selector %q matched no element", selector)
+	}
+
+	box := res.Value
+	if box.Get("width").Num() == 0 || box.Get("height").Num() == 0 {
+		return nil, fmt.Errorf("element matched by selector %q has no size", selector)
+	}
+
+	pad := float64(padding)
+	x := math.Max(0, box.Get("x").Num()-pad)
+	y := math.Max(0, box.Get("y").Num()-pad)
+	right := math.Min(box.Get("pageWidth").Num(), box.Get("x").Num()+box.Get("width").Num()+pad)
+	bottom := math.Min(box.Get("pageHeight").Num(), box.Get("y").Num()+box.Get("height").Num()+pad)
+	if right <= x || bottom <= y {
+		return nil, fmt.Errorf("element matched by selector %q is outside the page", selector)
+	}
+
+	return &proto.PageViewport{
+		X:      x,
+		Y:      y,
+		Width:  right - x,
+		Height: bottom - y,
+		Scale:  1,
+	}, nil
+}
@@ -0,0 +1,17 @@
+package screenshot
+
+import "testing"
+
+// Unexported helpers tested from screenshot_test.
+var (
+	ChunkClip = chunkClip
+	Stitch    = stitch
+)
+
+// SetThumbnailURL points YouTube thumbnail downloads at url, a format with
+// the video ID and quality, for the rest of the test.
+func SetThumbnailURL(t testing.TB, url string) {
+	original := thumbnailURL
+	thumbnailURL = url
+	t.Cleanup(func() { thumbnailURL = original })
+}
@@ -0,0 +1,320 @@
+package screenshot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// maxDownloadSize caps the images and PDFs downloaded by FileCapturer
+	maxDownloadSize = 100 << 20
+
+	// pdfRenderer renders PDF pages, from poppler-utils
+	pdfRenderer = "pdftoppm"
+
+	// contentTypeTimeout bounds the content type check that URLs with a
+	// file extension go through before the browser
+	contentTypeTimeout = 5 * time.Second
+)
+
+// fileExtensions are the URL path extensions FileCapturer handles.
+var fileExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+	".bmp":  true,
+	".tif":  true,
+	".tiff": true,
+	".pdf":  true,
+}
+
+// imageTypes are the image content types FileCapturer can decode.
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/bmp":  true,
+	"image/tiff": true,
+}
+
+// FileCapturer handles URLs that serve an image or a PDF instead of a page,
+// which the browser would show in its viewer. Only URLs whose path has an
+// image or PDF extension are handled, so pages cost no extra request. Images
+// are downloaded and saved as PNG, and PDFs have one page rendered with
+// pdftoppm. The content type is checked first, and anything else is left to
+// the next capturer.
+// PDFs that cannot be rendered fail for good, since the next capturer
+// would screenshot the viewer.
+type FileCapturer struct{}
+
+func (FileCapturer) CanHandle(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return fileExtensions[strings.ToLower(path.Ext(u.Path))]
+}
+
+func (FileCapturer) Capture(ctx context.Context, rawURL, dest string, config ScreenshotConfig) error {
+	detectCtx, cancel := context.WithTimeout(ctx, contentTypeTimeout)
+	contentType, err := detectContentType(detectCtx, rawURL, config)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		// The browser reports what is wrong with the URL better
+		return ErrNotHandled
+	}
+
+	ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	switch {
+	case imageTypes[contentType]:
+		return downloadImage(ctx, rawURL, dest, config)
+	case contentType == "application/pdf":
+		return Final(capturePDF(ctx, rawURL, dest, config))
+	default:
+		return ErrNotHandled
+	}
+}
+
+// detectContentType returns the media type served at rawURL, from a HEAD
+// request or, when that fails or is inconclusive, from the start of a GET
+// response, sniffing the content when the server does not say.
+func detectContentType(ctx context.Context, rawURL string, config ScreenshotConfig) (string, error) {
+	client := downloadClient(config)
+
+	var lastErr error
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := newDownloadRequest(ctx, method, rawURL, config)
+		if err != nil {
+			return "", err
+		}
+		if method == http.MethodGet {
+			req.Header.Set("Range", "bytes=0-511")
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if method == http.MethodGet && (contentType == "" || contentType == "application/octet-stream") {
+			head, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			contentType, _, _ = mime.ParseMediaType(http.DetectContentType(head))
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 400 {
+			lastErr = fmt.Errorf("HTTP %d", resp.StatusCode)
+			continue
+		}
+		if contentType == "" || contentType == "application/octet-stream" {
+			continue
+		}
+		return contentType, nil
+	}
+
+	if lastErr == nil {
+		return "", fmt.Errorf("unknown content type")
+	}
+	return "", lastErr
+}
+
+// newDownloadRequest builds a request for rawURL that identifies like the
+// browser and carries the site headers it would send. Cookies come from the
+// jar of downloadClient.
+func newDownloadRequest(ctx context.Context, method, rawURL string, config ScreenshotConfig) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.UserAgent != "" {
+		req.Header.Set("User-Agent", config.UserAgent)
+	}
+	config.Sites.Apply(req)
+
+	return req, nil
+}
+
+// downloadClient returns the client for requests built by
+// newDownloadRequest. Its cookie jar holds the cookies of config, so that
+// like in the browser they are sent on redirects to the hosts they belong
+// to.
+func downloadClient(config ScreenshotConfig) *http.Client {
+	client := siteClient(config)
+	if config.Cookies == nil {
+		return client
+	}
+
+	jar, _ := cookiejar.New(nil)
+	for _, cookie := range config.Cookies.Unexpired() {
+		scheme := "http"
+		if cookie.Secure {
+			scheme = "https"
+		}
+		host := strings.TrimPrefix(cookie.Domain, ".")
+		httpCookie := &http.Cookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HTTPOnly,
+			Expires:  cookie.Expires,
+		}
+		if !cookie.HostOnly {
+			httpCookie.Domain = cookie.Domain
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: "/"}, []*http.Cookie{httpCookie})
+	}
+	client.Jar = jar
+	return client
+}
+
+// download GETs rawURL, returning its body, at most maxDownloadSize bytes.
+func download(ctx context.Context, rawURL string, config ScreenshotConfig) ([]byte, error) {
+	req, err := newDownloadRequest(ctx, http.MethodGet, rawURL, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Large files can take longer than the usual download timeout
+	client := downloadClient(config)
+	client.Timeout = 0
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	if len(data) > maxDownloadSize {
+		return nil, fmt.Errorf("%s is larger than %d MB", rawURL, maxDownloadSize>>20)
+	}
+	return data, nil
+}
+
+// downloadImage saves the image at rawURL to dest as PNG.
+func downloadImage(ctx context.Context, rawURL, dest string, config ScreenshotConfig) error {
+	img, err := fetchImage(ctx, rawURL, config)
+	if err != nil {
+		return err
+	}
+
+	if err := imaging.Save(img, dest); err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	return nil
+}
+
+// fetchImage downloads and decodes the image at rawURL, rotated upright
+// when its EXIF data says so.
+func fetchImage(ctx context.Context, rawURL string, config ScreenshotConfig) (image.Image, error) {
+	data, err := download(ctx, rawURL, config)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// capturePDF renders a page of the PDF at rawURL to dest: the page in a
+// "#page=N" fragment, otherwise PDFPage, otherwise the first. Pages are
+// rendered at 96 DPI times the pixel ratio, matching browser captures.
+func capturePDF(ctx context.Context, rawURL, dest string, config ScreenshotConfig) error {
+	tool, err := exec.LookPath(pdfRenderer)
+	if err != nil {
+		return fmt.Errorf("rendering PDFs needs %s from poppler-utils: %w", pdfRenderer, err)
+	}
+
+	data, err := download(ctx, rawURL, config)
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "screenshot-tweets-pdf-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "document.pdf")
+	if err := os.WriteFile(input, data, filePermissions); err != nil {
+		return err
+	}
+
+	page := strconv.Itoa(pdfPage(rawURL, config.PDFPage))
+	dpi := strconv.FormatFloat(96*config.PixelRatio(), 'f', -1, 64)
+	output := filepath.Join(dir, "page")
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, tool, "-png", "-singlefile", "-f", page, "-l", page, "-r", dpi, input, output)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("failed to render PDF page %s: %s", page, message)
+		}
+		return fmt.Errorf("failed to render PDF page %s: %w", page, err)
+	}
+
+	rendered, err := os.ReadFile(output + ".png")
+	if err != nil {
+		return fmt.Errorf("failed to render PDF page %s: %w", page, err)
+	}
+	if err := os.WriteFile(dest, rendered, filePermissions); err != nil {
+		return fmt.Errorf("failed to write screenshot file: %w", err)
+	}
+	return nil
+}
+
+// pdfPage returns the page of a "#page=N" fragment in rawURL, otherwise
+// fallback, otherwise 1.
+func pdfPage(rawURL string, fallback int) int {
+	if u, err := url.Parse(rawURL); err == nil {
+		for _, param := range strings.Split(u.Fragment, "&") {
+			if value, ok := strings.CutPrefix(param, "page="); ok {
+				if page, err := strconv.Atoi(value); err == nil && page > 0 {
+					return page
+				}
+			}
+		}
+	}
+	return max(fallback, 1)
+}
@@ -0,0 +1,212 @@
+package screenshot_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFileServer(t *testing.T) *httptest.Server {
+	var photo bytes.Buffer
+	require.NoError(t, jpeg.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 640, 480)), nil))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photo.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write(photo.Bytes())
+		case "/paper.pdf":
+			// Servers that reject HEAD and do not label their files
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte("%PDF-1.7\n%fake document\n"))
+		case "/private.png":
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			assert.NoError(t, imaging.Encode(w, image.NewRGBA(image.Rect(0, 0, 10, 10)), imaging.PNG))
+		case "/broken.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("not a png"))
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html><body>Hello</body></html>"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFileCapturerImages(t *testing.T) {
+	server := newFileServer(t)
+	config := screenshot.NewDefaultConfig()
+	dest := filepath.Join(t.TempDir(), "day-1-screenshot.png")
+
+	require.NoError(t, screenshot.FileCapturer{}.Capture(context.Background(), server.URL+"/photo.jpg", dest, config))
+	img, err := imaging.Open(dest)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 640, 480), img.Bounds())
+	data, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("\x89PNG")), "saved as PNG")
+
+	err = screenshot.FileCapturer{}.Capture(context.Background(), server.URL+"/broken.png", dest, config)
+	assert.ErrorContains(t, err, "failed to decode image")
+
+	cookiesPath := filepath.Join(t.TempDir(), "cookies.json")
+	require.NoError(t, os.WriteFile(cookiesPath, []byte(`[{"name": "session", "value": "abc", "domain": "127.0.0.1"}]`), 0644))
+	config.Cookies, err = screenshot.LoadCookieJar(cookiesPath)
+	require.NoError(t, err)
+	require.NoError(t, screenshot.FileCapturer{}.Capture(context.Background(), server.URL+"/private.png", dest, config))
+}
+
+func TestFileCapturerCanHandle(t *testing.T) {
+	for _, test := range []struct {
+		url      string
+		expected bool
+	}{
+		{"https://example.com/photo.jpg", true},
+		{"https://example.com/scans/Photo.JPEG?size=large", true},
+		{"http://example.com/paper.pdf#page=3", true},
+		{"https://example.com/diagram.webp", true},
+		{"https://example.com/article", false},
+		{"https://example.com/blog/post.html", false},
+		{"https://example.com/?file=photo.jpg", false},
+		{"file:///tmp/photo.png", false},
+		{"not a url", false},
+	} {
+		assert.Equal(t, test.expected, screenshot.FileCapturer{}.CanHandle(test.url), test.url)
+	}
+}
+
+func TestFileCapturerLeavesPagesToTheBrowser(t *testing.T) {
+	server := newFileServer(t)
+	dest := filepath.Join(t.TempDir(), "day-1-screenshot.png")
+
+	// A file-looking URL that serves a page
+	err := screenshot.FileCapturer{}.Capture(context.Background(), server.URL+"/preview.png", dest, screenshot.NewDefaultConfig())
+	assert.ErrorIs(t, err, screenshot.ErrNotHandled)
+	assert.NoFileExists(t, dest)
+
+	var calls []string
+	registry := screenshot.NewRegistry()
+	registry.Register("file", screenshot.FileCapturer{})
+	registry.Register("browser", fakeCapturer{name: "browser", prefix: "http", calls: &calls})
+	require.NoError(t, registry.Capture(context.Background(), server.URL+"/preview.png", dest, screenshot.NewDefaultConfig()))
+	assert.Equal(t, []string{"browser"}, calls)
+}
+
+func TestFileCapturerPDF(t *testing.T) {
+	server := newFileServer(t)
+	dest := filepath.Join(t.TempDir(), "day-1-screenshot.png")
+
+	path := os.Getenv("PATH")
+	t.Setenv("PATH", t.TempDir())
+	err := screenshot.FileCapturer{}.Capture(context.Background(), server.URL+"/paper.pdf", dest, screenshot.NewDefaultConfig())
+	assert.ErrorContains(t, err, "rendering PDFs needs pdftoppm from poppler-utils")
+
+	// The browser would screenshot its PDF viewer
+	var calls []string
+	registry := screenshot.NewRegistry()
+	registry.Register("file", screenshot.FileCapturer{})
+	registry.Register("browser", fakeCapturer{name: "browser", prefix: "http", calls: &calls})
+	err = registry.Capture(context.Background(), server.URL+"/paper.pdf", dest, screenshot.NewDefaultConfig())
+	assert.ErrorContains(t, err, "rendering PDFs needs pdftoppm")
+	assert.Empty(t, calls)
+
+	// A stand-in for pdftoppm that records its arguments and writes a page
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	page := filepath.Join(binDir, "page.png")
+	require.NoError(t, imaging.Save(image.NewRGBA(image.Rect(0, 0, 816, 1056)), page))
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\nfor last; do :; done\ncp " + page + " \"$last.png\"\n"
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "pdftoppm"), []byte(script), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+path)
+
+	for _, test := range []struct {
+		name     string
+		url      string
+		pdfPage  int
+		scale    float64
+		expected string
+	}{
+		{"first page", "/paper.pdf", 0, 0, "-png -singlefile -f 1 -l 1 -r 96"},
+		{"configured page", "/paper.pdf", 3, 0, "-png -singlefile -f 3 -l 3 -r 96"},
+		{"page fragment", "/paper.pdf#page=5&zoom=100", 3, 0, "-png -singlefile -f 5 -l 5 -r 96"},
+		{"pixel ratio", "/paper.pdf", 0, 2, "-png -singlefile -f 1 -l 1 -r 192"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			config := screenshot.NewDefaultConfig()
+			config.PDFPage = test.pdfPage
+			config.Scale = test.scale
+
+			require.NoError(t, screenshot.FileCapturer{}.Capture(context.Background(), server.URL+test.url, dest, config))
+			args, err := os.ReadFile(argsFile)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(args), test.expected), string(args))
+
+			img, err := imaging.Open(dest)
+			require.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, 816, 1056), img.Bounds())
+		})
+	}
+}
+
+func TestFileCapturerRedirectDropsSiteHeaders(t *testing.T) {
+	servers := newRedirectServers(t)
+	config := screenshot.NewDefaultConfig()
+	config.Sites = servers.rules
+	dest := filepath.Join(t.TempDir(), "day-1-screenshot.png")
+
+	require.NoError(t, screenshot.FileCapturer{}.Capture(context.Background(), servers.origin+"/image.png", dest, config))
+	servers.assertNoTokenForwarded(t)
+}
+
+func TestFileCapturerCookiesFollowRedirects(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		assert.NoError(t, imaging.Encode(w, image.NewRGBA(image.Rect(0, 0, 10, 10)), imaging.PNG))
+	}))
+	t.Cleanup(cdn.Close)
+	cdnURL := strings.Replace(cdn.URL, "127.0.0.1", "localhost", 1)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := r.Cookie("session")
+		assert.Error(t, err, "the cookie belongs to the other host")
+		http.Redirect(w, r, cdnURL+"/private.png", http.StatusFound)
+	}))
+	t.Cleanup(origin.Close)
+
+	cookiesPath := filepath.Join(t.TempDir(), "cookies.json")
+	require.NoError(t, os.WriteFile(cookiesPath, []byte(`[{"name": "session", "value": "abc", "domain": "localhost", "path": "/"}]`), 0644))
+	config := screenshot.NewDefaultConfig()
+	var err error
+	config.Cookies, err = screenshot.LoadCookieJar(cookiesPath)
+	require.NoError(t, err)
+	dest := filepath.Join(t.TempDir(), "day-1-screenshot.png")
+
+	require.NoError(t, screenshot.FileCapturer{}.Capture(context.Background(), origin.URL+"/image.png", dest, config))
+	assert.FileExists(t, dest)
+}
@@ -0,0 +1,227 @@
+package screenshot
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+// fragmentWaitTimeout bounds the wait for content loaded by scrolling to a
+// fragment.
+const fragmentWaitTimeout = 3 * time.Second
+
+// Fragment is the target of a URL fragment: the element ID before any
+// fragment directive, and the text directives of "#:~:text=...".
+type Fragment struct {
+	ID    string          `json:"id,omitempty"`
+	Texts []TextDirective `json:"texts,omitempty"`
+}
+
+// TextDirective is a text fragment: the text from Start to End, or Start
+// alone when End is empty, between the optional Prefix and Suffix.
+type TextDirective struct {
+	Prefix string `json:"prefix,omitempty"`
+	Start  string `json:"start"`
+	End    string `json:"end,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+}
+
+// ParseFragment returns the fragment target of rawURL, which is empty when
+// it has none.
+func ParseFragment(rawURL string) Fragment {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Fragment{}
+	}
+
+	id, directives, _ := strings.Cut(u.EscapedFragment(), ":~:")
+	var fragment Fragment
+	if id, err := url.PathUnescape(id); err == nil {
+		fragment.ID = id
+	}
+
+	for _, directive := range strings.Split(directives, "&") {
+		value, ok := strings.CutPrefix(directive, "text=")
+		if !ok {
+			continue
+		}
+		if text, ok := parseTextDirective(value); ok {
+			fragment.Texts = append(fragment.Texts, text)
+		}
+	}
+
+	return fragment
+}
+
+// parseTextDirective parses "[prefix-,]start[,end][,-suffix]". The dashes
+// are matched before unescaping, since dashes in the text are escaped.
+func parseTextDirective(value string) (TextDirective, bool) {
+	parts := strings.Split(value, ",")
+
+	var text TextDirective
+	if len(parts) > 1 && strings.HasSuffix(parts[0], "-") {
+		text.Prefix = strings.TrimSuffix(parts[0], "-")
+		parts = parts[1:]
+	}
+	if len(parts) > 1 && strings.HasPrefix(parts[len(parts)-1], "-") {
+		text.Suffix = strings.TrimPrefix(parts[len(parts)-1], "-")
+		parts = parts[:len(parts)-1]
+	}
+	switch len(parts) {
+	case 1:
+		text.Start = parts[0]
+	case 2:
+		text.Start, text.End = parts[0], parts[1]
+	default:
+		return TextDirective{}, false
+	}
+
+	for _, part := range []*string{&text.Prefix, &text.Start, &text.End, &text.Suffix} {
+		unescaped, err := url.PathUnescape(*part)
+		if err != nil {
+			return TextDirective{}, false
+		}
+		*part = unescaped
+	}
+
+	return text, text.Start != ""
+}
+
+// IsEmpty reports whether f has nothing to scroll to.
+func (f Fragment) IsEmpty() bool {
+	return f.ID == "" && len(f.Texts) == 0
+}
+
+func (f Fragment) String() string {
+	if len(f.Texts) == 0 {
+		return "#" + f.ID
+	}
+	texts := make([]string, len(f.Texts))
+	for i, text := range f.Texts {
+		texts[i] = fmt.Sprintf("%q", text)
+	}
+	return "text " + strings.Join(texts, ", ")
+}
+
+func (t TextDirective) String() string {
+	text := t.Start
+	if t.End != "" {
+		text += "…" + t.End
+	}
+	return text
+}
+
+// scrollToFragment scrolls the target of the fragment of rawURL into view
+// for viewport captures, and highlights matched text when HighlightText is
+// set. Like in browsers, matched text takes precedence over the element
+// ID. Text that is not found is reported to OnWarning, while a missing
+// element is ignored since fragments are also used for routing.
+func scrollToFragment(page *rod.Page, rawURL string, config ScreenshotConfig) error {
+	fragment := ParseFragment(rawURL)
+	if fragment.IsEmpty() {
+		return nil
+	}
+
+	texts := fragment.Texts
+	if texts == nil {
+		texts = []TextDirective{}
+	}
+	scroll := config.Selector == "" && !config.FullPage
+
+	res, err := page.Eval(`(id, texts, scroll, highlight) => {
+		const normalize = s => s.toLowerCase().replace(/\s+/g, ' ').trim();
+
+		// The visible text of the page with whitespace collapsed, and the
+		// text node and offset of every character in it
+		const walker = document.createTreeWalker(document.body || document.documentElement, NodeFilter.SHOW_TEXT, {
+			acceptNode: node => node.parentElement && !node.parentElement.closest('script, style, noscript, template')
+				? NodeFilter.FILTER_ACCEPT : NodeFilter.FILTER_REJECT,
+		});
+		let haystack = '';
+		const positions = [];
+		for (let node; (node = walker.nextNode());) {
+			for (let i = 0; i < node.data.length; i++) {
+				const space = /\s/.test(node.data[i]);
+				if (space && (haystack === '' || haystack.endsWith(' '))) continue;
+				haystack += space ? ' ' : node.data[i].toLowerCase();
+				positions.push([node, i]);
+			}
+			if (haystack !== '' && !haystack.endsWith(' ')) {
+				haystack += ' ';
+				positions.push(positions[positions.length - 1]);
+			}
+		}
+
+		const find = text => {
+			const start = normalize(text.start), end = normalize(text.end || '');
+			const prefix = normalize(text.prefix || ''), suffix = normalize(text.suffix || '');
+			for (let from = 0; ;) {
+				const index = haystack.indexOf(start, from);
+				if (index < 0 || start === '') return null;
+				from = index + 1;
+				if (prefix && !haystack.slice(0, index).trimEnd().endsWith(prefix)) continue;
+				let stop = index + start.length;
+				if (end) {
+					const endIndex = haystack.indexOf(end, stop);
+					if (endIndex < 0) return null;
+					stop = endIndex + end.length;
+				}
+				if (suffix && !haystack.slice(stop).trimStart().startsWith(suffix)) continue;
+
+				const range = document.createRange();
+				const [startNode, startOffset] = positions[index];
+				const [endNode, endOffset] = positions[stop - 1];
+				range.setStart(startNode, startOffset);
+				range.setEnd(endNode, Math.min(endOffset + 1, endNode.data.length));
+				return range;
+			}
+		};
+
+		const ranges = [], missing = [];
+		texts.forEach((text, i) => {
+			const range = find(text);
+			range ? ranges.push(range) : missing.push(i);
+		});
+
+		if (highlight && ranges.length > 0 && window.Highlight && CSS.highlights) {
+			CSS.highlights.set('screenshot-tweets', new Highlight(...ranges));
+			const style = document.createElement('style');
+			style.textContent = '::highlight(screenshot-tweets) { background-color: #ffe066; color: #000 }';
+			(document.head || document.documentElement).appendChild(style);
+		}
+
+		let target = null, block = 'start';
+		if (ranges.length > 0) {
+			target = ranges[0].startContainer.parentElement;
+			block = 'center';
+		} else if (id) {
+			target = document.getElementById(id) || document.getElementsByName(id)[0] || null;
+		}
+		const scrolled = !!(scroll && target);
+		if (scrolled) {
+			target.scrollIntoView({block, behavior: 'instant'});
+		}
+
+		return {missing, scrolled};
+	}`, fragment.ID, texts, scroll, config.HighlightText)
+	if err != nil {
+		return fmt.Errorf("failed to scroll to %s: %w", fragment, err)
+	}
+
+	for _, i := range res.Value.Get("missing").Arr() {
+		config.warn(fmt.Errorf("text fragment %q not found on the page", texts[i.Int()]))
+	}
+
+	if !res.Value.Get("scrolled").Bool() {
+		return nil
+	}
+	// Give images that were lazily loaded by the scroll a moment to arrive,
+	// but not long, since the network of some pages never goes idle
+	waitPage := page.Timeout(fragmentWaitTimeout)
+	defer waitPage.CancelTimeout()
+	_ = WaitStep{Kind: WaitIdle, Duration: 500 * time.Millisecond}.wait(waitPage)
+	return nil
+}
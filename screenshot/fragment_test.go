@@ -0,0 +1,50 @@
+package screenshot_test
+
+import (
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFragment(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		url      string
+		expected screenshot.Fragment
+	}{
+		{"no fragment", "https://example.com/post", screenshot.Fragment{}},
+		{"element id", "https://example.com/post#section-3", screenshot.Fragment{ID: "section-3"}},
+		{"escaped id", "https://example.com/post#caf%C3%A9", screenshot.Fragment{ID: "café"}},
+		{
+			"text",
+			"https://example.com/post#:~:text=the%20quick%20fox",
+			screenshot.Fragment{Texts: []screenshot.TextDirective{{Start: "the quick fox"}}},
+		},
+		{
+			"text range with context",
+			"https://example.com/post#:~:text=over-,the%20lazy,dog,-and%2Dthen",
+			screenshot.Fragment{Texts: []screenshot.TextDirective{{Prefix: "over", Start: "the lazy", End: "dog", Suffix: "and-then"}}},
+		},
+		{
+			"id and several texts",
+			"https://example.com/post#intro:~:text=first&unknown=1&text=second",
+			screenshot.Fragment{ID: "intro", Texts: []screenshot.TextDirective{{Start: "first"}, {Start: "second"}}},
+		},
+		{"too many parts", "https://example.com/post#:~:text=a,b,c", screenshot.Fragment{}},
+		{"empty text", "https://example.com/post#:~:text=", screenshot.Fragment{}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fragment := screenshot.ParseFragment(test.url)
+			assert.Equal(t, test.expected, fragment)
+			assert.Equal(t, test.expected.ID == "" && test.expected.Texts == nil, fragment.IsEmpty())
+		})
+	}
+}
+
+func TestFragmentString(t *testing.T) {
+	assert.Equal(t, "#section-3", screenshot.ParseFragment("https://example.com/#section-3").String())
+	assert.Equal(t, `text "the lazy…dog", "second"`,
+		screenshot.ParseFragment("https://example.com/#:~:text=over-,the%20lazy,dog&text=second").String())
+}
@@ -0,0 +1,106 @@
+package screenshot
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// maxChunkHeight keeps every single capture well below the size Chrome can
+// render in one go, in device pixels. Taller areas are captured in chunks
+// and stitched.
+const maxChunkHeight = 4096
+
+// pageClip returns the whole document as a clip area.
+func pageClip(page *rod.Page, width int) (*proto.PageViewport, error) {
+	res, err := page.Eval(`() => Math.max(document.documentElement.scrollHeight, document.body ? document.body.scrollHeight : 0)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure page height: %w", err)
+	}
+
+	return &proto.PageViewport{
+		Width:  float64(width),
+		Height: math.Max(1, res.Value.Num()),
+		Scale:  1,
+	}, nil
+}
+
+// captureClip captures the document area clip as a PNG at pixelRatio, in
+// chunks of at most maxChunkHeight device pixels when it is taller than that.
+func captureClip(page *rod.Page, clip *proto.PageViewport, pixelRatio float64) ([]byte, error) {
+	chunks := chunkClip(clip, pixelRatio)
+	if len(chunks) == 1 {
+		return page.Screenshot(false, &proto.PageCaptureScreenshot{
+			Format:                proto.PageCaptureScreenshotFormatPng,
+			Clip:                  clip,
+			CaptureBeyondViewport: true,
+		})
+	}
+
+	images := make([]image.Image, 0, len(chunks))
+	for _, chunk := range chunks {
+		data, err := page.Screenshot(false, &proto.PageCaptureScreenshot{
+			Format:                proto.PageCaptureScreenshotFormatPng,
+			Clip:                  chunk,
+			CaptureBeyondViewport: true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to capture chunk at %.0fpx: %w", chunk.Y, err)
+		}
+
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode chunk at %.0fpx: %w", chunk.Y, err)
+		}
+		images = append(images, img)
+	}
+
+	return stitch(images)
+}
+
+// chunkClip splits clip from top to bottom into areas of at most
+// maxChunkHeight device pixels at pixelRatio.
+func chunkClip(clip *proto.PageViewport, pixelRatio float64) []*proto.PageViewport {
+	chunkHeight := math.Max(1, math.Floor(maxChunkHeight/pixelRatio))
+	if clip.Height <= chunkHeight {
+		return []*proto.PageViewport{clip}
+	}
+
+	var chunks []*proto.PageViewport
+	for offset := 0.0; offset < clip.Height; offset += chunkHeight {
+		chunk := *clip
+		chunk.Y = clip.Y + offset
+		chunk.Height = math.Min(chunkHeight, clip.Height-offset)
+		chunks = append(chunks, &chunk)
+	}
+	return chunks
+}
+
+// stitch stacks images vertically and encodes the result as a PNG.
+func stitch(images []image.Image) ([]byte, error) {
+	width, height := 0, 0
+	for _, img := range images {
+		width = max(width, img.Bounds().Dx())
+		height += img.Bounds().Dy()
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	y := 0
+	for _, img := range images {
+		bounds := img.Bounds()
+		draw.Draw(canvas, image.Rect(0, y, bounds.Dx(), y+bounds.Dy()), img, bounds.Min, draw.Src)
+		y += bounds.Dy()
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode stitched screenshot: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,108 @@
+package screenshot_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkClip(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		clip       proto.PageViewport
+		pixelRatio float64
+		expected   [][2]float64
+	}{
+		{
+			name:       "fits in one chunk",
+			clip:       proto.PageViewport{Width: 800, Height: 4096, Scale: 1},
+			pixelRatio: 1,
+			expected:   [][2]float64{{0, 4096}},
+		},
+		{
+			name:       "last partial chunk",
+			clip:       proto.PageViewport{Width: 800, Height: 10000, Scale: 1},
+			pixelRatio: 1,
+			expected:   [][2]float64{{0, 4096}, {4096, 4096}, {8192, 1808}},
+		},
+		{
+			name:       "pixel ratio",
+			clip:       proto.PageViewport{Width: 800, Height: 5000, Scale: 1},
+			pixelRatio: 2,
+			expected:   [][2]float64{{0, 2048}, {2048, 2048}, {4096, 904}},
+		},
+		{
+			name:       "fractional pixel ratio",
+			clip:       proto.PageViewport{Width: 412, Height: 5000, Scale: 1},
+			pixelRatio: 2.625,
+			expected:   [][2]float64{{0, 1560}, {1560, 1560}, {3120, 1560}, {4680, 320}},
+		},
+		{
+			name:       "offset clip",
+			clip:       proto.PageViewport{X: 10, Y: 300, Width: 500, Height: 5000.5, Scale: 1},
+			pixelRatio: 1,
+			expected:   [][2]float64{{300, 4096}, {4396, 904.5}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			chunks := screenshot.ChunkClip(&test.clip, test.pixelRatio)
+
+			var actual [][2]float64
+			for _, chunk := range chunks {
+				assert.Equal(t, test.clip.X, chunk.X)
+				assert.Equal(t, test.clip.Width, chunk.Width)
+				assert.LessOrEqual(t, chunk.Height*test.pixelRatio, 4096.0)
+				actual = append(actual, [2]float64{chunk.Y, chunk.Height})
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestStitch(t *testing.T) {
+	solid := func(width, height int, c color.Color) image.Image {
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		return img
+	}
+	red := color.NRGBA{R: 255, A: 255}
+	green := color.NRGBA{G: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+
+	// Chunks of a 2.625 ratio capture, the last one partial
+	data, err := screenshot.Stitch([]image.Image{
+		solid(1082, 4095, red),
+		solid(1082, 4095, green),
+		solid(1082, 840, blue),
+	})
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 1082, 9030), img.Bounds())
+	for _, test := range []struct {
+		y        int
+		expected color.Color
+	}{
+		{0, red},
+		{4094, red},
+		{4095, green},
+		{8189, green},
+		{8190, blue},
+		{9029, blue},
+	} {
+		assert.Equal(t, test.expected, color.NRGBAModel.Convert(img.At(541, test.y)), "y=%d", test.y)
+	}
+}
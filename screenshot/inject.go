@@ -0,0 +1,131 @@
+package screenshot
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-rod/rod"
+)
+
+// Injection is CSS or JavaScript injected into pages after they load and
+// before the screenshot, into every page or only those whose host matches
+// Domain. Source names it in messages.
+type Injection struct {
+	Source string `json:"source"`
+	Domain string `json:"domain,omitempty"`
+	CSS    string `json:"css,omitempty"`
+	JS     string `json:"js,omitempty"`
+}
+
+// LoadInjection reads the CSS or JavaScript file at path, telling them
+// apart by extension.
+func LoadInjection(path string) (Injection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Injection{}, fmt.Errorf("failed to read injection %s: %w", path, err)
+	}
+
+	injection := Injection{Source: path}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".css":
+		injection.CSS = string(data)
+	case ".js":
+		injection.JS = string(data)
+	default:
+		return Injection{}, fmt.Errorf("injection %s must be a .css or .js file", path)
+	}
+	return injection, nil
+}
+
+func (i Injection) String() string {
+	return i.Source
+}
+
+func (i Injection) Validate() error {
+	if i.Domain != "" {
+		if err := validateDomainPattern(i.Domain); err != nil {
+			return err
+		}
+	}
+	if i.CSS == "" && i.JS == "" {
+		return fmt.Errorf("injection is empty")
+	}
+	if i.CSS != "" && i.JS != "" {
+		return fmt.Errorf("injection cannot be both CSS and JavaScript")
+	}
+	return nil
+}
+
+// Matches reports whether i is injected into the page at rawURL.
+func (i Injection) Matches(rawURL string) bool {
+	if i.Domain == "" {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	return err == nil && matchesDomain(i.Domain, u.Hostname())
+}
+
+// MatchInjections returns the injections for the page at rawURL, in order.
+func MatchInjections(injections []Injection, rawURL string) []Injection {
+	var matched []Injection
+	for _, injection := range injections {
+		if injection.Matches(rawURL) {
+			matched = append(matched, injection)
+		}
+	}
+	return matched
+}
+
+// inject runs the injections of config matching rawURL on page in order.
+// Scripts run as the body of an async function, so they can await. A
+// failing injection is reported to OnInjectError and the rest still run,
+// unless FailOnInjectError is set.
+func inject(page *rod.Page, rawURL string, config ScreenshotConfig) error {
+	injections := MatchInjections(config.Inject, rawURL)
+	if len(injections) == 0 {
+		return nil
+	}
+
+	for _, injection := range injections {
+		var err error
+		if injection.CSS != "" {
+			_, err = page.Eval(`(css) => {
+				const style = document.createElement('style')
+				style.textContent = css
+				;(document.head || document.documentElement).appendChild(style)
+			}`, injection.CSS)
+		} else {
+			_, err = page.Eval("async () => {\n" + injection.JS + "\n}")
+		}
+		if err == nil {
+			continue
+		}
+
+		if config.FailOnInjectError {
+			return fmt.Errorf("injection %s failed: %w", injection, err)
+		}
+		if config.OnInjectError != nil {
+			config.OnInjectError(injection.Source, err)
+		}
+	}
+
+	// Let the page render the changes before capturing. This fails too when
+	// a script navigated or reloaded the page, which is blamed on the last.
+	_, err := page.Eval(`() => new Promise(resolve => requestAnimationFrame(() => requestAnimationFrame(resolve)))`)
+	if err == nil {
+		return nil
+	}
+
+	last := injections[len(injections)-1]
+	err = fmt.Errorf("failed waiting for the page to render after injecting: %w", err)
+	if config.FailOnInjectError {
+		return fmt.Errorf("injection %s failed: %w", last, err)
+	}
+	if config.OnInjectError != nil {
+		config.OnInjectError(last.Source, err)
+	}
+	return nil
+}
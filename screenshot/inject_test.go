@@ -0,0 +1,78 @@
+package screenshot_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadInjection(t *testing.T) {
+	tempDir := t.TempDir()
+	for name, content := range map[string]string{
+		"hide.css":  "header { display: none }",
+		"expand.JS": "document.querySelector('details').open = true",
+		"notes.txt": "hello",
+		"empty.css": "",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644))
+	}
+
+	injection, err := screenshot.LoadInjection(filepath.Join(tempDir, "hide.css"))
+	require.NoError(t, err)
+	assert.Equal(t, "header { display: none }", injection.CSS)
+	assert.Empty(t, injection.JS)
+	assert.NoError(t, injection.Validate())
+
+	injection, err = screenshot.LoadInjection(filepath.Join(tempDir, "expand.JS"))
+	require.NoError(t, err)
+	assert.Equal(t, "document.querySelector('details').open = true", injection.JS)
+	assert.Equal(t, filepath.Join(tempDir, "expand.JS"), injection.String())
+
+	_, err = screenshot.LoadInjection(filepath.Join(tempDir, "notes.txt"))
+	assert.ErrorContains(t, err, "notes.txt must be a .css or .js file")
+
+	_, err = screenshot.LoadInjection(filepath.Join(tempDir, "missing.css"))
+	assert.ErrorContains(t, err, "failed to read injection")
+
+	injection, err = screenshot.LoadInjection(filepath.Join(tempDir, "empty.css"))
+	require.NoError(t, err)
+	assert.EqualError(t, injection.Validate(), "injection is empty")
+}
+
+func TestInjectionValidate(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		injection screenshot.Injection
+		err       string
+	}{
+		{"css", screenshot.Injection{CSS: "a {}"}, ""},
+		{"js for a domain", screenshot.Injection{Domain: "*.medium.com", JS: "1"}, ""},
+		{"both", screenshot.Injection{CSS: "a {}", JS: "1"}, "injection cannot be both CSS and JavaScript"},
+		{"invalid domain", screenshot.Injection{Domain: "https://medium.com", CSS: "a {}"}, `invalid domain pattern "https://medium.com", use a host name or *.example.com`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.injection.Validate()
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestMatchInjections(t *testing.T) {
+	global := screenshot.Injection{Source: "global.css", CSS: "a {}"}
+	medium := screenshot.Injection{Source: "medium.js", Domain: "*.medium.com", JS: "1"}
+	github := screenshot.Injection{Source: "github.css", Domain: "github.com", CSS: "b {}"}
+	injections := []screenshot.Injection{global, medium, github}
+
+	assert.Equal(t, []screenshot.Injection{global, medium}, screenshot.MatchInjections(injections, "https://blog.medium.com/post"))
+	assert.Equal(t, []screenshot.Injection{global, github}, screenshot.MatchInjections(injections, "https://GitHub.com/go-rod/rod"))
+	assert.Equal(t, []screenshot.Injection{global}, screenshot.MatchInjections(injections, "https://medium.com/"))
+}
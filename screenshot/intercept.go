@@ -0,0 +1,100 @@
+package screenshot
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// interceptsRequests reports whether captures with config need to intercept
+// the requests of the page.
+func interceptsRequests(config ScreenshotConfig) bool {
+	_, _, proxyAuth := config.Proxy.credentials()
+	return (config.Blocklist != nil && config.Blocklist.Len() > 0) || len(config.Sites) > 0 || proxyAuth
+}
+
+// interceptRequests fails the requests of page that the blocklist of config
+// blocks, calling OnBlocked for each, adds the headers of the matching site
+// rules to the others and answers proxy authentication challenges, until
+// the returned function is called. WebSockets cannot be intercepted, so
+// blocking them disables the WebSocket API instead.
+func interceptRequests(page *rod.Page, config ScreenshotConfig) (func(), error) {
+	blocklist := config.Blocklist
+	if blocklist == nil {
+		blocklist = NewBlocklist()
+	}
+
+	if blocklist.types["websocket"] {
+		if _, err := page.EvalOnNewDocument(`window.WebSocket = function () { throw new Error("WebSocket blocked"); };`); err != nil {
+			return nil, err
+		}
+	}
+
+	username, password, proxyAuth := config.Proxy.credentials()
+	err := proto.FetchEnable{
+		Patterns:           []*proto.FetchRequestPattern{{URLPattern: "*"}},
+		HandleAuthRequests: proxyAuth,
+	}.Call(page)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(page.GetContext())
+	wait := page.Context(ctx).EachEvent(func(e *proto.FetchRequestPaused) {
+		go continueRequest(page, e, blocklist, config)
+	}, func(e *proto.FetchAuthRequired) {
+		response := &proto.FetchAuthChallengeResponse{Response: proto.FetchAuthChallengeResponseResponseDefault}
+		if proxyAuth && e.AuthChallenge.Source == proto.FetchAuthChallengeSourceProxy {
+			response = &proto.FetchAuthChallengeResponse{
+				Response: proto.FetchAuthChallengeResponseResponseProvideCredentials,
+				Username: username,
+				Password: password,
+			}
+		}
+		go proto.FetchContinueWithAuth{RequestID: e.RequestID, AuthChallengeResponse: response}.Call(page)
+	})
+	go wait()
+
+	return func() {
+		cancel()
+		proto.FetchDisable{}.Call(page)
+	}, nil
+}
+
+// continueRequest fails the paused request e when blocked, and otherwise
+// lets it through with the headers of the site rules that match it. The
+// navigation to the page itself is never blocked.
+func continueRequest(page *rod.Page, e *proto.FetchRequestPaused, blocklist *Blocklist, config ScreenshotConfig) {
+	navigation := e.ResourceType == proto.NetworkResourceTypeDocument && e.FrameID == page.FrameID
+	if !navigation && blocklist.Blocks(e.Request.URL, string(e.ResourceType)) {
+		proto.FetchFailRequest{RequestID: e.RequestID, ErrorReason: proto.NetworkErrorReasonBlockedByClient}.Call(page)
+		if config.OnBlocked != nil {
+			config.OnBlocked(e.Request.URL)
+		}
+		return
+	}
+
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		proto.FetchContinueRequest{RequestID: e.RequestID}.Call(page)
+		return
+	}
+
+	req := &http.Request{URL: u, Header: make(http.Header)}
+	for name, value := range e.Request.Headers {
+		req.Header.Set(name, value.String())
+	}
+	if !config.Sites.Apply(req) {
+		proto.FetchContinueRequest{RequestID: e.RequestID}.Call(page)
+		return
+	}
+
+	headers := make([]*proto.FetchHeaderEntry, 0, len(req.Header))
+	for name := range req.Header {
+		headers = append(headers, &proto.FetchHeaderEntry{Name: name, Value: req.Header.Get(name)})
+	}
+	proto.FetchContinueRequest{RequestID: e.RequestID, Headers: headers}.Call(page)
+}
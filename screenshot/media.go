@@ -0,0 +1,27 @@
+package screenshot
+
+import (
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// emulateMedia applies the media type and media features requested by
+// config to page.
+func emulateMedia(page *rod.Page, config ScreenshotConfig) error {
+	var features []*proto.EmulationMediaFeature
+	if config.ColorScheme != "" {
+		features = append(features, &proto.EmulationMediaFeature{Name: "prefers-color-scheme", Value: config.ColorScheme})
+	}
+	if config.ReducedMotion {
+		features = append(features, &proto.EmulationMediaFeature{Name: "prefers-reduced-motion", Value: "reduce"})
+	}
+
+	if config.MediaType == "" && len(features) == 0 {
+		return nil
+	}
+
+	return proto.EmulationSetEmulatedMedia{
+		Media:    config.MediaType,
+		Features: features,
+	}.Call(page)
+}
@@ -0,0 +1,126 @@
+package screenshot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// maxPageSize caps how much of a page is read looking for its meta tags,
+// which belong in the head.
+const maxPageSize = 1 << 20
+
+var (
+	metaTagRegex   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attributeRegex = regexp.MustCompile(`(?is)([a-z][a-z:_-]*)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+	// previewImageTags are the meta tags naming a preview image, preferred
+	// in this order
+	previewImageTags = []string{"og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src"}
+)
+
+// OpenGraphCapturer saves the image a page publishes for link previews, in
+// its og:image or twitter:image meta tag, instead of a screenshot. Images
+// smaller than OGImageMinWidth by OGImageMinHeight pixels are rejected so
+// the next capturer can take a screenshot instead.
+type OpenGraphCapturer struct{}
+
+func (OpenGraphCapturer) CanHandle(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+func (OpenGraphCapturer) Capture(ctx context.Context, rawURL, dest string, config ScreenshotConfig) error {
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	page, err := fetchPage(ctx, rawURL, config)
+	if err != nil {
+		return err
+	}
+
+	imageURL, ok := previewImageURL(page, rawURL)
+	if !ok {
+		return ErrNotHandled
+	}
+
+	img, err := fetchImage(ctx, imageURL, config)
+	if err != nil {
+		return fmt.Errorf("failed to fetch preview image %s: %w", imageURL, err)
+	}
+
+	size := img.Bounds().Size()
+	if size.X < config.OGImageMinWidth || size.Y < config.OGImageMinHeight {
+		return fmt.Errorf("preview image %s is %dx%d, smaller than %dx%d",
+			imageURL, size.X, size.Y, config.OGImageMinWidth, config.OGImageMinHeight)
+	}
+
+	if err := imaging.Save(img, dest); err != nil {
+		return fmt.Errorf("failed to write image: %w", err)
+	}
+	return nil
+}
+
+// fetchPage returns up to maxPageSize bytes of the page at rawURL.
+func fetchPage(ctx context.Context, rawURL string, config ScreenshotConfig) ([]byte, error) {
+	req, err := newDownloadRequest(ctx, http.MethodGet, rawURL, config)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := downloadClient(config).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+}
+
+// previewImageURL returns the preview image named by the meta tags of page,
+// resolved against pageURL.
+func previewImageURL(page []byte, pageURL string) (string, bool) {
+	images := make(map[string]string)
+	for _, tag := range metaTagRegex.FindAll(page, -1) {
+		var name, content string
+		for _, attr := range attributeRegex.FindAllSubmatch(tag, -1) {
+			value := html.UnescapeString(string(attr[2]) + string(attr[3]) + string(attr[4]))
+			switch strings.ToLower(string(attr[1])) {
+			case "property", "name":
+				name = strings.ToLower(strings.TrimSpace(value))
+			case "content":
+				content = strings.TrimSpace(value)
+			}
+		}
+		if _, seen := images[name]; !seen && content != "" {
+			images[name] = content
+		}
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", false
+	}
+	for _, tag := range previewImageTags {
+		ref, err := url.Parse(images[tag])
+		if images[tag] == "" || err != nil {
+			continue
+		}
+		resolved := base.ResolveReference(ref)
+		if resolved.Scheme == "http" || resolved.Scheme == "https" {
+			return resolved.String(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,120 @@
+package screenshot_test
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newOpenGraphServer(t *testing.T) *httptest.Server {
+	pages := map[string]string{
+		"/post":      `<html><head><meta property="og:title" content="Post"><meta content="/images/card.png" property="og:image"></head></html>`,
+		"/thread":    `<html><head><META NAME='twitter:image' CONTENT='//{{host}}/images/card.png?size=large&amp;v=2'></head></html>`,
+		"/preferred": `<head><meta name="twitter:image" content="/images/icon.png"><meta property="og:image" content="/images/card.png"></head>`,
+		"/icon":      `<head><meta property="og:image" content="/images/icon.png"></head>`,
+		"/plain":     `<html><head><title>No preview</title></head></html>`,
+		"/data":      `<head><meta property="og:image" content="data:image/png;base64,AAAA"></head>`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/images/card.png":
+			if r.URL.RawQuery != "" && r.URL.RawQuery != "size=large&v=2" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			assert.NoError(t, imaging.Encode(w, image.NewRGBA(image.Rect(0, 0, 1200, 630)), imaging.PNG))
+		case "/images/icon.png":
+			assert.NoError(t, imaging.Encode(w, image.NewRGBA(image.Rect(0, 0, 64, 64)), imaging.PNG))
+		default:
+			page, ok := pages[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, strings.ReplaceAll(page, "{{host}}", r.Host))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenGraphCapturer(t *testing.T) {
+	server := newOpenGraphServer(t)
+	config := screenshot.NewDefaultConfig()
+	config.OGImageMinWidth, config.OGImageMinHeight = 600, 315
+
+	for _, test := range []struct {
+		name          string
+		path          string
+		errorContains string
+		notHandled    bool
+	}{
+		{"og:image", "/post", "", false},
+		{"twitter:image", "/thread", "", false},
+		{"og:image is preferred", "/preferred", "", false},
+		{"too small", "/icon", "is 64x64, smaller than 600x315", false},
+		{"no preview image", "/plain", "", true},
+		{"only http images", "/data", "", true},
+		{"missing page", "/gone", "HTTP 404", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "day-1-screenshot.png")
+			err := screenshot.OpenGraphCapturer{}.Capture(context.Background(), server.URL+test.path, dest, config)
+
+			switch {
+			case test.notHandled:
+				assert.ErrorIs(t, err, screenshot.ErrNotHandled)
+			case test.errorContains != "":
+				assert.ErrorContains(t, err, test.errorContains)
+				assert.NoFileExists(t, dest)
+			default:
+				require.NoError(t, err)
+				img, err := imaging.Open(dest)
+				require.NoError(t, err)
+				assert.Equal(t, image.Rect(0, 0, 1200, 630), img.Bounds())
+			}
+		})
+	}
+}
+
+func TestOpenGraphFallsBackToScreenshots(t *testing.T) {
+	server := newOpenGraphServer(t)
+	config := screenshot.NewDefaultConfig()
+	config.OGImageMinWidth, config.OGImageMinHeight = 600, 315
+
+	var calls []string
+	registry := screenshot.NewRegistry()
+	registry.Register("opengraph", screenshot.OpenGraphCapturer{})
+	registry.Register("browser", fakeCapturer{name: "browser", prefix: "http", calls: &calls})
+
+	dest := filepath.Join(t.TempDir(), "day-1-screenshot.png")
+	for _, path := range []string{"/icon", "/plain"} {
+		require.NoError(t, registry.Capture(context.Background(), server.URL+path, dest, config))
+	}
+	assert.Equal(t, []string{"browser", "browser"}, calls)
+}
+
+func TestOpenGraphRedirectDropsSiteHeaders(t *testing.T) {
+	servers := newRedirectServers(t)
+	config := screenshot.NewDefaultConfig()
+	config.Sites = servers.rules
+	dest := filepath.Join(t.TempDir(), "day-1-screenshot.png")
+
+	// The other host serves no page, so there is no preview image either
+	err := screenshot.OpenGraphCapturer{}.Capture(context.Background(), servers.origin+"/post", dest, config)
+	assert.ErrorIs(t, err, screenshot.ErrNotHandled)
+	servers.assertNoTokenForwarded(t)
+}
@@ -0,0 +1,130 @@
+package screenshot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+// OverlayRule dismisses one kind of overlay, such as a consent framework's
+// dialog. The first visible element matching one of Click is clicked, then
+// every visible element matching Hide is hidden.
+type OverlayRule struct {
+	Name  string   `json:"name" yaml:"name"`
+	Click []string `json:"click,omitempty" yaml:"click"`
+	Hide  []string `json:"hide,omitempty" yaml:"hide"`
+}
+
+func (r OverlayRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("overlay rule needs a name")
+	}
+	if len(r.Click) == 0 && len(r.Hide) == 0 {
+		return fmt.Errorf("overlay rule %q needs click or hide selectors", r.Name)
+	}
+	return nil
+}
+
+// DefaultOverlayRules cover the common consent frameworks, newsletter
+// pop-ups and "open in app" banners.
+var DefaultOverlayRules = []OverlayRule{
+	{Name: "onetrust", Click: []string{"#onetrust-accept-btn-handler"}, Hide: []string{"#onetrust-consent-sdk"}},
+	{Name: "cookiebot", Click: []string{"#CybotCookiebotDialogBodyLevelButtonLevelOptinAllowAll", "#CybotCookiebotDialogBodyButtonAccept"}, Hide: []string{"#CybotCookiebotDialog", "#CybotCookiebotDialogBodyUnderlay"}},
+	{Name: "quantcast", Click: []string{"#qc-cmp2-ui button[mode=primary]"}, Hide: []string{"#qc-cmp2-container"}},
+	{Name: "didomi", Click: []string{"#didomi-notice-agree-button"}, Hide: []string{"#didomi-host"}},
+	{Name: "trustarc", Click: []string{"#truste-consent-button"}, Hide: []string{"#truste-consent-track", ".truste_overlay", ".truste_box_overlay"}},
+	{Name: "usercentrics", Hide: []string{"#usercentrics-root", "#usercentrics-cmp-ui"}},
+	{Name: "sourcepoint", Hide: []string{"div[id^='sp_message_container']"}},
+	{Name: "funding-choices", Click: []string{".fc-cta-consent"}, Hide: []string{".fc-consent-root"}},
+	{Name: "cookieyes", Click: []string{".cky-btn-accept"}, Hide: []string{".cky-consent-container", ".cky-overlay"}},
+	{Name: "complianz", Click: []string{".cmplz-accept"}, Hide: []string{"#cmplz-cookiebanner-container"}},
+	{Name: "osano", Click: []string{".osano-cm-accept-all"}, Hide: []string{".osano-cm-window"}},
+	{Name: "klaro", Click: []string{".klaro .cm-btn-success"}, Hide: []string{".klaro"}},
+	{Name: "cookie-notice", Click: []string{"#cn-accept-cookie"}, Hide: []string{"#cookie-notice"}},
+	{Name: "newsletter", Hide: []string{".mc-modal", ".mc-modal-bg", ".pum-overlay", "#newsletter-popup", ".newsletter-modal"}},
+	{Name: "app-banner", Hide: []string{".smartbanner", "#smartbanner", ".smart-app-banner", "#branch-banner-iframe"}},
+}
+
+// OverlayAction is a dismissal made by an OverlayRule.
+type OverlayAction struct {
+	Rule     string `json:"rule"`
+	Action   string `json:"action"`
+	Selector string `json:"selector"`
+}
+
+const dismissOverlaysJS = `rules => {
+	const visible = el => {
+		const rect = el.getBoundingClientRect();
+		const style = getComputedStyle(el);
+		return rect.width > 0 && rect.height > 0 && style.visibility !== 'hidden' && style.display !== 'none';
+	};
+	const query = (selector, all) => {
+		try {
+			return all ? Array.from(document.querySelectorAll(selector)) : [document.querySelector(selector)].filter(Boolean);
+		} catch (e) {
+			return [];
+		}
+	};
+
+	const fired = [];
+	for (const rule of rules) {
+		for (const selector of rule.click || []) {
+			const el = query(selector, false).find(visible);
+			if (el) {
+				el.click();
+				fired.push({rule: rule.name, action: 'click', selector});
+				break;
+			}
+		}
+		for (const selector of rule.hide || []) {
+			const els = query(selector, true).filter(visible);
+			els.forEach(el => el.style.setProperty('display', 'none', 'important'));
+			if (els.length > 0) {
+				fired.push({rule: rule.name, action: 'hide', selector});
+			}
+		}
+	}
+
+	// Modals usually lock scrolling while they are open
+	if (fired.length > 0) {
+		for (const el of [document.documentElement, document.body]) {
+			if (el && getComputedStyle(el).overflow === 'hidden') {
+				el.style.setProperty('overflow', 'visible', 'important');
+			}
+		}
+	}
+	return fired;
+}`
+
+// dismissOverlays applies rules to page and returns what they did.
+func dismissOverlays(page *rod.Page, rules []OverlayRule) ([]OverlayAction, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	res, err := page.Eval(dismissOverlaysJS, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dismiss overlays: %w", err)
+	}
+
+	var actions []OverlayAction
+	if err := res.Value.Unmarshal(&actions); err != nil {
+		return nil, fmt.Errorf("failed to read dismissed overlays: %w", err)
+	}
+
+	for _, action := range actions {
+		if action.Action == "click" {
+			// Give the dialog a moment to close before the screenshot
+			ctx := page.GetContext()
+			select {
+			case <-ctx.Done():
+				return actions, ctx.Err()
+			case <-time.After(500 * time.Millisecond):
+			}
+			break
+		}
+	}
+
+	return actions, nil
+}
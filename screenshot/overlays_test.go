@@ -0,0 +1,32 @@
+package screenshot_test
+
+import (
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultOverlayRules(t *testing.T) {
+	names := make(map[string]bool)
+	for _, rule := range screenshot.DefaultOverlayRules {
+		assert.NoError(t, rule.Validate(), rule.Name)
+		assert.False(t, names[rule.Name], "duplicate rule %s", rule.Name)
+		names[rule.Name] = true
+	}
+
+	assert.True(t, names["onetrust"])
+	assert.True(t, names["cookiebot"])
+}
+
+func TestOverlayRuleValidate(t *testing.T) {
+	err := screenshot.OverlayRule{Click: []string{"#accept"}}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "needs a name")
+
+	err = screenshot.OverlayRule{Name: "empty"}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `overlay rule "empty" needs click or hide selectors`)
+}
@@ -3,6 +3,7 @@ package screenshot
 import (
 	"fmt"
 	"image"
+	"math"
 	"path/filepath"
 	"strings"
 
@@ -13,14 +14,61 @@ type SocialMediaPlatform struct {
 	Name   string `json:"name"`
 	Width  int    `json:"width"`
 	Height int    `json:"height"`
+	Suffix string `json:"suffix,omitempty"`
+	Format string `json:"format,omitempty"`
 }
 
+// PlatformPresets are the built-in platforms that can be selected by key.
+var PlatformPresets = map[string]SocialMediaPlatform{
+	"twitter":            {Name: "Twitter/X", Width: 1200, Height: 628},
+	"linkedin":           {Name: "LinkedIn", Width: 1200, Height: 627},
+	"mastodon":           {Name: "Mastodon", Width: 1200, Height: 675},
+	"bluesky":            {Name: "Bluesky", Width: 1200, Height: 630},
+	"facebook":           {Name: "Facebook", Width: 1200, Height: 630},
+	"instagram":          {Name: "Instagram", Width: 1080, Height: 1080},
+	"instagram-portrait": {Name: "Instagram (4:5)", Width: 1080, Height: 1350},
+	"threads":            {Name: "Threads", Width: 1080, Height: 1350},
+}
+
+// PlatformConfigs is the set of platforms generated when none is configured.
 var PlatformConfigs = map[string]SocialMediaPlatform{
-	"twitter":  {Name: "Twitter/X", Width: 1200, Height: 628},
-	"linkedin": {Name: "LinkedIn", Width: 1200, Height: 627},
+	"twitter":  PlatformPresets["twitter"],
+	"linkedin": PlatformPresets["linkedin"],
+}
+
+// Filename returns the variant filename for a screenshot named
+// nameWithoutExt, using key when the platform has no suffix.
+func (p SocialMediaPlatform) Filename(key, nameWithoutExt string) string {
+	suffix := p.Suffix
+	if suffix == "" {
+		suffix = key
+	}
+
+	ext := "png"
+	if p.Format == "jpeg" || p.Format == "jpg" {
+		ext = "jpg"
+	}
+
+	return fmt.Sprintf("%s-%s.%s", nameWithoutExt, suffix, ext)
+}
+
+func (p SocialMediaPlatform) Validate() error {
+	if p.Width <= 0 || p.Height <= 0 {
+		return fmt.Errorf("dimensions must be positive, got %dx%d", p.Width, p.Height)
+	}
+
+	switch p.Format {
+	case "", "png", "jpeg", "jpg":
+	default:
+		return fmt.Errorf("unsupported format %q, use png or jpeg", p.Format)
+	}
+
+	return nil
 }
 
-func ResizeForSocialMedia(originalFile, baseFilename string) error {
+// ResizeForSocialMedia writes a crop of originalFile for each of platforms
+// next to it, named after baseFilename.
+func ResizeForSocialMedia(originalFile, baseFilename string, platforms map[string]SocialMediaPlatform) error {
 	img, err := imaging.Open(originalFile)
 	if err != nil {
 		return fmt.Errorf("failed to open image %s: %w", originalFile, err)
@@ -29,11 +77,10 @@ func ResizeForSocialMedia(originalFile, baseFilename string) error {
 	baseDir := filepath.Dir(originalFile)
 	nameWithoutExt := strings.TrimSuffix(baseFilename, filepath.Ext(baseFilename))
 
-	for platform, config := range PlatformConfigs {
+	for platform, config := range platforms {
 		resizedImg := SmartCrop(img, config.Width, config.Height)
 
-		platformFilename := fmt.Sprintf("%s-%s.png", nameWithoutExt, platform)
-		platformPath := filepath.Join(baseDir, platformFilename)
+		platformPath := filepath.Join(baseDir, config.Filename(platform, nameWithoutExt))
 
 		if err := imaging.Save(resizedImg, platformPath); err != nil {
 			return fmt.Errorf("failed to save %s optimized image: %w", platform, err)
@@ -43,56 +90,58 @@ func ResizeForSocialMedia(originalFile, baseFilename string) error {
 	return nil
 }
 
+// SmartCrop fills targetWidth x targetHeight with the center of img,
+// resampling it only once so high resolution sources are downsampled
+// straight to the target size.
 func SmartCrop(img image.Image, targetWidth, targetHeight int) image.Image {
-	bounds := img.Bounds()
-	originalWidth := bounds.Dx()
-	originalHeight := bounds.Dy()
+	resized := imaging.Fill(img, targetWidth, targetHeight, imaging.Center, imaging.Lanczos)
 
-	targetRatio := float64(targetWidth) / float64(targetHeight)
-	originalRatio := float64(originalWidth) / float64(originalHeight)
+	return imaging.Sharpen(resized, 0.5)
+}
 
-	var resized image.Image
+// NormalizeResolution scales the image at path down by pixelRatio, so a
+// screenshot captured at a high device pixel ratio is saved at its CSS
+// pixel size.
+func NormalizeResolution(path string, pixelRatio float64) error {
+	if pixelRatio <= 1 {
+		return nil
+	}
 
-	if originalRatio > targetRatio {
-		newHeight := int(float64(originalWidth) / targetRatio)
-		if newHeight <= originalHeight {
-			resized = imaging.Crop(img, image.Rect(0, 0, originalWidth, newHeight))
-		} else {
-			resized = imaging.Resize(img, targetWidth, 0, imaging.Lanczos)
-		}
-	} else if originalRatio < targetRatio {
-		newWidth := int(float64(originalHeight) * targetRatio)
-		if newWidth <= originalWidth {
-			resized = imaging.Crop(img, image.Rect(0, 0, newWidth, originalHeight))
-		} else {
-			resized = imaging.Resize(img, 0, targetHeight, imaging.Lanczos)
-		}
-	} else {
-		resized = imaging.Resize(img, targetWidth, targetHeight, imaging.Lanczos)
+	img, err := imaging.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open image %s: %w", path, err)
 	}
 
-	resized = imaging.Fill(resized, targetWidth, targetHeight, imaging.Center, imaging.Lanczos)
+	width := int(math.Round(float64(img.Bounds().Dx()) / pixelRatio))
+	resized := imaging.Resize(img, max(width, 1), 0, imaging.Lanczos)
 
-	return imaging.Sharpen(resized, 0.5)
+	if err := imaging.Save(resized, path); err != nil {
+		return fmt.Errorf("failed to save normalized image: %w", err)
+	}
+	return nil
 }
 
-func GenerateSocialMediaFilenames(day int) map[string]string {
+// GenerateSocialMediaFilenames returns the filename of each of platforms
+// for the screenshot of day.
+func GenerateSocialMediaFilenames(day int, platforms map[string]SocialMediaPlatform) map[string]string {
 	baseFilename := fmt.Sprintf("day-%d-screenshot", day)
 	filenames := make(map[string]string)
 
-	for platform := range PlatformConfigs {
-		filenames[platform] = fmt.Sprintf("%s-%s.png", baseFilename, platform)
+	for platform, config := range platforms {
+		filenames[platform] = config.Filename(platform, baseFilename)
 	}
 
 	return filenames
 }
 
-func GenerateAllFilenames(day int) map[string]string {
+// GenerateAllFilenames returns the filenames of GenerateSocialMediaFilenames
+// plus the original screenshot.
+func GenerateAllFilenames(day int, platforms map[string]SocialMediaPlatform) map[string]string {
 	filenames := make(map[string]string)
 
 	filenames["original"] = fmt.Sprintf("day-%d-screenshot.png", day)
 
-	for platform, filename := range GenerateSocialMediaFilenames(day) {
+	for platform, filename := range GenerateSocialMediaFilenames(day, platforms) {
 		filenames[platform] = filename
 	}
 
@@ -77,7 +77,7 @@ func TestResizeForSocialMedia(t *testing.T) {
 	require.NoError(t, err)
 
 	baseFilename := "test-screenshot.png"
-	err = screenshot.ResizeForSocialMedia(originalFile, baseFilename)
+	err = screenshot.ResizeForSocialMedia(originalFile, baseFilename, screenshot.PlatformConfigs)
 	require.NoError(t, err)
 
 	twitterFile := filepath.Join(tempDir, "test-screenshot-twitter.png")
@@ -103,13 +103,13 @@ func TestResizeForSocialMedia(t *testing.T) {
 }
 
 func TestResizeForSocialMediaInvalidFile(t *testing.T) {
-	err := screenshot.ResizeForSocialMedia("/non/existent/file.png", "test.png")
+	err := screenshot.ResizeForSocialMedia("/non/existent/file.png", "test.png", screenshot.PlatformConfigs)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to open image")
 }
 
 func TestGenerateSocialMediaFilenames(t *testing.T) {
-	filenames := screenshot.GenerateSocialMediaFilenames(7)
+	filenames := screenshot.GenerateSocialMediaFilenames(7, screenshot.PlatformConfigs)
 
 	assert.Contains(t, filenames, "twitter")
 	assert.Contains(t, filenames, "linkedin")
@@ -119,7 +119,7 @@ func TestGenerateSocialMediaFilenames(t *testing.T) {
 }
 
 func TestGenerateAllFilenames(t *testing.T) {
-	filenames := screenshot.GenerateAllFilenames(3)
+	filenames := screenshot.GenerateAllFilenames(3, screenshot.PlatformConfigs)
 
 	assert.Contains(t, filenames, "original")
 	assert.Contains(t, filenames, "twitter")
@@ -130,3 +130,78 @@ func TestGenerateAllFilenames(t *testing.T) {
 	assert.Equal(t, "day-3-screenshot-linkedin.png", filenames["linkedin"])
 }
 
+func TestPlatformFilename(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		platform screenshot.SocialMediaPlatform
+		expected string
+	}{
+		{"key as suffix", screenshot.SocialMediaPlatform{}, "day-1-screenshot-mastodon.png"},
+		{"custom suffix", screenshot.SocialMediaPlatform{Suffix: "toot"}, "day-1-screenshot-toot.png"},
+		{"jpeg format", screenshot.SocialMediaPlatform{Format: "jpeg"}, "day-1-screenshot-mastodon.jpg"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.platform.Filename("mastodon", "day-1-screenshot"))
+		})
+	}
+}
+
+func TestPlatformValidate(t *testing.T) {
+	assert.NoError(t, screenshot.PlatformPresets["bluesky"].Validate())
+
+	err := screenshot.SocialMediaPlatform{Width: 1200}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dimensions must be positive")
+
+	err = screenshot.SocialMediaPlatform{Width: 10, Height: 10, Format: "gif"}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported format "gif"`)
+}
+
+func TestResizeForConfiguredPlatforms(t *testing.T) {
+	tempDir := t.TempDir()
+	originalPath := filepath.Join(tempDir, "original.png")
+	require.NoError(t, imaging.Save(createTestImage(800, 600), originalPath))
+
+	platforms := map[string]screenshot.SocialMediaPlatform{
+		"instagram": screenshot.PlatformPresets["instagram"],
+		"banner":    {Name: "Banner", Width: 300, Height: 100, Format: "jpeg"},
+	}
+	require.NoError(t, screenshot.ResizeForSocialMedia(originalPath, "original.png", platforms))
+
+	for filename, size := range map[string][2]int{
+		"original-instagram.png": {1080, 1080},
+		"original-banner.jpg":    {300, 100},
+	} {
+		img, err := imaging.Open(filepath.Join(tempDir, filename))
+		require.NoError(t, err, filename)
+		assert.Equal(t, size[0], img.Bounds().Dx(), filename)
+		assert.Equal(t, size[1], img.Bounds().Dy(), filename)
+	}
+
+	_, err := os.Stat(filepath.Join(tempDir, "original-twitter.png"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, map[string]string{
+		"original":  "day-2-screenshot.png",
+		"instagram": "day-2-screenshot-instagram.png",
+		"banner":    "day-2-screenshot-banner.jpg",
+	}, screenshot.GenerateAllFilenames(2, platforms))
+}
+
+func TestNormalizeResolution(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "day-1-screenshot.png")
+	require.NoError(t, imaging.Save(image.NewRGBA(image.Rect(0, 0, 1179, 2556)), path))
+
+	require.NoError(t, screenshot.NormalizeResolution(path, 1))
+	img, err := imaging.Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1179, img.Bounds().Dx())
+
+	require.NoError(t, screenshot.NormalizeResolution(path, 3))
+	img, err = imaging.Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 393, 852), img.Bounds())
+
+	assert.Error(t, screenshot.NormalizeResolution(filepath.Join(t.TempDir(), "missing.png"), 2))
+}
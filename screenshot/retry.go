@@ -0,0 +1,28 @@
+package screenshot
+
+import (
+	"context"
+
+	apperrors "screenshot-tweets/internal/errors"
+)
+
+// CaptureScreenshotWithRetry wraps CaptureScreenshotContext with retryConfig. Each
+// failure is classified through NewScreenshotError so the configured
+// retryable error types decide whether another attempt is made.
+func CaptureScreenshotWithRetry(ctx context.Context, url, filename string, day int, config ScreenshotConfig, retryConfig apperrors.RetryConfig) error {
+	session := NewSession(config)
+	defer session.Close()
+
+	return session.CaptureWithRetry(ctx, url, filename, day, config, retryConfig)
+}
+
+// CaptureWithRetry is the Session counterpart of CaptureScreenshotWithRetry,
+// capturing with config as in CaptureWithConfig.
+func (s *Session) CaptureWithRetry(ctx context.Context, url, filename string, day int, config ScreenshotConfig, retryConfig apperrors.RetryConfig) error {
+	return retryConfig.Do(ctx, func(attempt int) error {
+		if err := s.CaptureWithConfig(ctx, url, filename, config); err != nil {
+			return apperrors.NewScreenshotError(url, day, err)
+		}
+		return nil
+	})
+}
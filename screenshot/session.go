@@ -0,0 +1,292 @@
+package screenshot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/launcher/flags"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Session owns a single browser shared by every capture made through it.
+// Chrome is launched on the first capture that needs it and every capture
+// runs in its own incognito context, so cookies and storage never leak
+// between entries. A Session must be closed when no longer needed.
+//
+// Captures go through the session's Registry, which by default tries the
+// "youtube" thumbnail capturer, the "file" capturer for images and PDFs and
+// then the "browser" capturer. The "opengraph" capturer is registered but
+// only used when an order lists it.
+type Session struct {
+	config   ScreenshotConfig
+	registry *Registry
+
+	mu       sync.Mutex
+	launcher *launcher.Launcher
+	browser  *rod.Browser
+	closed   bool
+}
+
+func NewSession(config ScreenshotConfig) *Session {
+	s := &Session{config: config, registry: NewRegistry()}
+	s.registry.Register("youtube", YouTubeThumbnailCapturer{})
+	s.registry.Register("file", FileCapturer{})
+	s.registry.Register("browser", &BrowserCapturer{session: s})
+	s.registry.Register("opengraph", OpenGraphCapturer{})
+	// The Open Graph capturer is only used when an order lists it. The
+	// names were just registered, so this cannot fail.
+	s.registry.SetOrder("youtube", "file", "browser")
+	return s
+}
+
+func (s *Session) Registry() *Registry {
+	return s.registry
+}
+
+// Config returns the settings the session was created with.
+func (s *Session) Config() ScreenshotConfig {
+	return s.config
+}
+
+func (s *Session) Capture(url, filename string) error {
+	return s.CaptureContext(context.Background(), url, filename)
+}
+
+// CaptureContext is like Capture but stops as soon as ctx is done. The
+// configured Timeout still applies on top of ctx.
+func (s *Session) CaptureContext(ctx context.Context, url, filename string) error {
+	return s.CaptureWithConfig(ctx, url, filename, s.config)
+}
+
+// CaptureWithConfig is like CaptureContext but uses config, usually a
+// modified copy of Config, for this capture only. The browser is still the
+// one launched from the session's own config.
+func (s *Session) CaptureWithConfig(ctx context.Context, url, filename string, config ScreenshotConfig) error {
+	return s.registry.Capture(ctx, url, filepath.Join(config.OutputDir, filename), config)
+}
+
+// Close shuts down the browser and removes its profile directory. It is safe
+// to call more than once.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	return s.shutdown()
+}
+
+// BrowserCapturer captures the viewport of any http(s) URL with the
+// session's browser.
+type BrowserCapturer struct {
+	session *Session
+}
+
+func (b *BrowserCapturer) CanHandle(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+func (b *BrowserCapturer) Capture(ctx context.Context, url, dest string, config ScreenshotConfig) error {
+	return b.session.captureRegularScreenshot(ctx, url, dest, config)
+}
+
+func (s *Session) captureRegularScreenshot(ctx context.Context, url, dest string, config ScreenshotConfig) error {
+	browser, err := s.getBrowser()
+	if err != nil {
+		return err
+	}
+
+	incognito, err := browser.Incognito()
+	if err != nil {
+		// The browser is most likely gone, relaunch it on the next capture
+		s.reset(browser)
+		return fmt.Errorf("failed to create browser context: %w", err)
+	}
+	defer incognito.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	page, err := incognito.Context(ctx).Page(proto.TargetCreateTarget{URL: ""})
+	if err != nil {
+		return fmt.Errorf("failed to create page: %w", err)
+	}
+	defer page.Close()
+
+	if err := emulateDevice(page, config); err != nil {
+		return fmt.Errorf("failed to set viewport: %w", err)
+	}
+
+	if err := page.SetUserAgent(&proto.NetworkSetUserAgentOverride{
+		UserAgent: config.UserAgent,
+	}); err != nil {
+		return fmt.Errorf("failed to set user agent: %w", err)
+	}
+
+	if err := emulateMedia(page, config); err != nil {
+		return fmt.Errorf("failed to emulate media: %w", err)
+	}
+
+	if interceptsRequests(config) {
+		stop, err := interceptRequests(page, config)
+		if err != nil {
+			return fmt.Errorf("failed to intercept requests: %w", err)
+		}
+		defer stop()
+	}
+
+	// Every cookie is set with its own scope, so that sessions survive
+	// redirects to other hosts and reach subresources on sibling domains
+	if config.Cookies != nil {
+		if cookies := config.Cookies.Unexpired(); len(cookies) > 0 {
+			if err := setCookies(page, cookies); err != nil {
+				return fmt.Errorf("failed to set cookies: %w", err)
+			}
+		}
+	}
+
+	if err := page.Navigate(url); err != nil {
+		return fmt.Errorf("failed to navigate to URL: %w", err)
+	}
+
+	waitSteps := config.Wait
+	if waitSteps == nil {
+		waitSteps = DefaultWaitSteps
+	}
+	if err := WaitForPage(page, waitSteps); err != nil {
+		// If page load fails, still attempt to take a screenshot
+		// This handles cases where pages timeout but are still partially loaded
+		config.warn(fmt.Errorf("page load incomplete (%w), capturing anyway", err))
+	}
+
+	actions, err := dismissOverlays(page, config.OverlayRules)
+	if err != nil {
+		config.warn(err)
+	}
+	if config.OnOverlay != nil {
+		for _, action := range actions {
+			config.OnOverlay(action)
+		}
+	}
+
+	if err := inject(page, url, config); err != nil {
+		return err
+	}
+
+	if err := scrollToFragment(page, url, config); err != nil {
+		config.warn(err)
+	}
+
+	screenshot, err := takeScreenshot(page, config)
+	if err != nil {
+		return fmt.Errorf("failed to capture screenshot: %w", err)
+	}
+
+	if err := os.WriteFile(dest, screenshot, filePermissions); err != nil {
+		return fmt.Errorf("failed to write screenshot file: %w", err)
+	}
+
+	return nil
+}
+
+// takeScreenshot captures the element matching the configured selector, the
+// whole page in full-page mode, or otherwise the viewport.
+func takeScreenshot(page *rod.Page, config ScreenshotConfig) ([]byte, error) {
+	var clip *proto.PageViewport
+	if config.Selector != "" {
+		var err error
+		if clip, err = elementClip(page, config.Selector, config.Padding); err != nil {
+			fallback := "viewport"
+			if config.FullPage {
+				fallback = "full page"
+			}
+			config.warn(fmt.Errorf("%w, capturing the %s instead", err, fallback))
+		}
+	}
+
+	if clip == nil && config.FullPage {
+		var err error
+		if clip, err = pageClip(page, config.ViewportWidth); err != nil {
+			return nil, err
+		}
+	}
+
+	if clip == nil {
+		return page.Screenshot(false, &proto.PageCaptureScreenshot{
+			Format: proto.PageCaptureScreenshotFormatPng,
+		})
+	}
+
+	if config.MaxHeight > 0 && clip.Height > float64(config.MaxHeight) {
+		clip.Height = float64(config.MaxHeight)
+	}
+	return captureClip(page, clip, config.PixelRatio())
+}
+
+func (s *Session) getBrowser() (*rod.Browser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, fmt.Errorf("capture session is closed")
+	}
+
+	if s.browser != nil {
+		return s.browser, nil
+	}
+
+	l := launcher.New().Headless(true)
+	if s.config.BrowserPath != "" {
+		l = l.Bin(s.config.BrowserPath)
+	}
+	if proxy := s.config.Proxy; proxy != nil {
+		l = l.Proxy(proxy.server())
+		if len(proxy.Bypass) > 0 {
+			l = l.Set(flags.Flag("proxy-bypass-list"), strings.Join(proxy.Bypass, ";"))
+		}
+	}
+
+	u, err := l.Launch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to launch browser: %w", err)
+	}
+
+	browser := rod.New().ControlURL(u)
+	if err := browser.Connect(); err != nil {
+		l.Kill()
+		l.Cleanup()
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+
+	s.launcher = l
+	s.browser = browser
+	return browser, nil
+}
+
+func (s *Session) reset(browser *rod.Browser) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.browser == browser {
+		s.shutdown()
+	}
+}
+
+func (s *Session) shutdown() error {
+	var err error
+	if s.browser != nil {
+		err = s.browser.Close()
+		s.browser = nil
+	}
+	if s.launcher != nil {
+		s.launcher.Kill()
+		s.launcher.Cleanup()
+		s.launcher = nil
+	}
+	return err
+}
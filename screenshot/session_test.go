@@ -0,0 +1,61 @@
+package screenshot_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionCloseWithoutCapture(t *testing.T) {
+	session := screenshot.NewSession(screenshot.NewDefaultConfig())
+
+	assert.NoError(t, session.Close())
+	assert.NoError(t, session.Close())
+}
+
+func TestSessionCaptureAfterClose(t *testing.T) {
+	config := screenshot.NewDefaultConfig()
+	config.OutputDir = t.TempDir()
+
+	session := screenshot.NewSession(config)
+	require.NoError(t, session.Close())
+
+	err := session.Capture("https://example.com", "test.png")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "session is closed")
+}
+
+func TestCaptureReportsWarnings(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping screenshot test in short mode")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body><p>Hello</p></body></html>")
+	}))
+	t.Cleanup(server.Close)
+
+	config := screenshot.NewDefaultConfig()
+	config.OutputDir = t.TempDir()
+	config.CaptureOrder = []string{"browser"}
+	config.Wait = []screenshot.WaitStep{}
+	config.Selector = "#missing"
+	var warnings []string
+	config.OnWarning = func(err error) { warnings = append(warnings, err.Error()) }
+
+	session := screenshot.NewSession(config)
+	defer session.Close()
+	if err := session.Capture(server.URL+"/#:~:text=absent", "day-1-screenshot.png"); err != nil {
+		t.Skipf("browser unavailable: %v", err)
+	}
+
+	require.Len(t, warnings, 2)
+	assert.Equal(t, `text fragment "absent" not found on the page`, warnings[0])
+	assert.Contains(t, warnings[1], "capturing the viewport instead")
+}
@@ -0,0 +1,127 @@
+package screenshot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SiteRule adds request headers and basic auth credentials to requests for
+// hosts matching Domain: a host name, or "*.example.com" for the subdomains
+// of example.com.
+type SiteRule struct {
+	Domain   string
+	Headers  map[string]string
+	Username string
+	Password string
+}
+
+// String describes the rule without its header values and credentials.
+func (r SiteRule) String() string {
+	return r.Domain
+}
+
+func (r SiteRule) Validate() error {
+	if err := validateDomainPattern(r.Domain); err != nil {
+		return err
+	}
+
+	for name := range r.Headers {
+		if name == "" || strings.ContainsAny(name, " :\t\r\n") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+	}
+
+	if r.Password != "" && r.Username == "" {
+		return fmt.Errorf("basic auth needs a username")
+	}
+
+	return nil
+}
+
+// Matches reports whether the rule applies to requests for host.
+func (r SiteRule) Matches(host string) bool {
+	return matchesDomain(r.Domain, host)
+}
+
+func validateDomainPattern(pattern string) error {
+	domain := strings.TrimPrefix(pattern, "*.")
+	if domain == "" || strings.ContainsAny(domain, "*/:") {
+		return fmt.Errorf("invalid domain pattern %q, use a host name or *.example.com", pattern)
+	}
+	return nil
+}
+
+// matchesDomain reports whether host is pattern, or one of its subdomains
+// when pattern is "*.example.com".
+func matchesDomain(pattern, host string) bool {
+	host = strings.ToLower(host)
+	domain := strings.ToLower(pattern)
+	if parent, ok := strings.CutPrefix(domain, "*."); ok {
+		return strings.HasSuffix(host, "."+parent)
+	}
+	return host == domain
+}
+
+// SiteRules are applied in order, later rules overriding the headers and
+// credentials of earlier ones.
+type SiteRules []SiteRule
+
+// Match returns the rules that apply to requests for rawURL.
+func (s SiteRules) Match(rawURL string) []SiteRule {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	var rules []SiteRule
+	for _, rule := range s {
+		if rule.Matches(u.Hostname()) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Apply sets the headers and basic auth of the rules matching req on it,
+// reporting whether any rule matched.
+func (s SiteRules) Apply(req *http.Request) bool {
+	rules := s.Match(req.URL.String())
+	for _, rule := range rules {
+		for name, value := range rule.Headers {
+			req.Header.Set(name, value)
+		}
+		if rule.Username != "" {
+			req.SetBasicAuth(rule.Username, rule.Password)
+		}
+	}
+	return len(rules) > 0
+}
+
+// siteClient returns the client for requests that carry the site rules of
+// config. Redirects to another host drop the headers of the site rules of
+// the original host, and carry those of the new host.
+func siteClient(config ScreenshotConfig) *http.Client {
+	client := httpClient(config.Proxy)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		// The headers of every redirect are copied from the first request
+		original := via[0]
+		if strings.EqualFold(req.URL.Hostname(), original.URL.Hostname()) {
+			return nil
+		}
+		for _, rule := range config.Sites.Match(original.URL.String()) {
+			for name := range rule.Headers {
+				req.Header.Del(name)
+			}
+		}
+		config.Sites.Apply(req)
+		return nil
+	}
+	return client
+}
@@ -0,0 +1,147 @@
+package screenshot_test
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiteRuleMatches(t *testing.T) {
+	exact := screenshot.SiteRule{Domain: "staging.example.com"}
+	wildcard := screenshot.SiteRule{Domain: "*.example.com"}
+
+	for _, test := range []struct {
+		host     string
+		exact    bool
+		wildcard bool
+	}{
+		{"staging.example.com", true, true},
+		{"Staging.Example.com", true, true},
+		{"eu.staging.example.com", false, true},
+		{"example.com", false, false},
+		{"notexample.com", false, false},
+	} {
+		t.Run(test.host, func(t *testing.T) {
+			assert.Equal(t, test.exact, exact.Matches(test.host))
+			assert.Equal(t, test.wildcard, wildcard.Matches(test.host))
+		})
+	}
+}
+
+func TestSiteRulesApply(t *testing.T) {
+	sites := screenshot.SiteRules{
+		{Domain: "*.example.com", Headers: map[string]string{"X-Preview-Token": "general", "X-Team": "docs"}},
+		{Domain: "staging.example.com", Headers: map[string]string{"X-Preview-Token": "staging"}, Username: "deploy", Password: "hunter2"},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://staging.example.com/dashboard", nil)
+	require.NoError(t, err)
+	assert.True(t, sites.Apply(req))
+	assert.Equal(t, "staging", req.Header.Get("X-Preview-Token"))
+	assert.Equal(t, "docs", req.Header.Get("X-Team"))
+	username, password, ok := req.BasicAuth()
+	require.True(t, ok)
+	assert.Equal(t, "deploy", username)
+	assert.Equal(t, "hunter2", password)
+
+	req, err = http.NewRequest(http.MethodGet, "https://img.youtube.com/vi/abc/default.jpg", nil)
+	require.NoError(t, err)
+	assert.False(t, sites.Apply(req))
+	assert.Empty(t, req.Header)
+
+	assert.Equal(t, "[*.example.com staging.example.com]", fmt.Sprint(sites.Match("https://staging.example.com/")))
+}
+
+func TestSiteRuleValidate(t *testing.T) {
+	assert.NoError(t, screenshot.SiteRule{Domain: "*.example.com", Headers: map[string]string{"X-Token": "a"}}.Validate())
+
+	for _, test := range []struct {
+		name          string
+		rule          screenshot.SiteRule
+		errorContains string
+	}{
+		{"empty domain", screenshot.SiteRule{}, `invalid domain pattern ""`},
+		{"url", screenshot.SiteRule{Domain: "https://example.com"}, `invalid domain pattern "https://example.com"`},
+		{"inner wildcard", screenshot.SiteRule{Domain: "staging.*.com"}, "use a host name or *.example.com"},
+		{"header name", screenshot.SiteRule{Domain: "example.com", Headers: map[string]string{"X Token": "a"}}, `invalid header name "X Token"`},
+		{"password only", screenshot.SiteRule{Domain: "example.com", Password: "hunter2"}, "basic auth needs a username"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.rule.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errorContains)
+		})
+	}
+}
+
+// redirectServers is an origin on 127.0.0.1 that expects a site header and
+// redirects every request to the same path on a second host, which records
+// the site headers it receives and serves an image.
+type redirectServers struct {
+	origin string
+	rules  screenshot.SiteRules
+
+	mu     sync.Mutex
+	tokens []string
+}
+
+func newRedirectServers(t *testing.T) *redirectServers {
+	servers := &redirectServers{
+		rules: screenshot.SiteRules{{Domain: "127.0.0.1", Headers: map[string]string{"X-Preview-Token": "secret"}}},
+	}
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		servers.mu.Lock()
+		servers.tokens = append(servers.tokens, r.Header.Get("X-Preview-Token"))
+		servers.mu.Unlock()
+		w.Header().Set("Content-Type", "image/png")
+		assert.NoError(t, imaging.Encode(w, image.NewRGBA(image.Rect(0, 0, 1200, 630)), imaging.PNG))
+	}))
+	t.Cleanup(other.Close)
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Preview-Token"))
+		http.Redirect(w, r, otherURL+r.URL.Path, http.StatusFound)
+	}))
+	t.Cleanup(origin.Close)
+	servers.origin = origin.URL
+
+	return servers
+}
+
+// assertNoTokenForwarded checks that the second host was reached without
+// the site header of the origin.
+func (s *redirectServers) assertNoTokenForwarded(t *testing.T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	require.NotEmpty(t, s.tokens)
+	for _, token := range s.tokens {
+		assert.Empty(t, token)
+	}
+}
+
+func TestThumbnailRedirectDropsSiteHeaders(t *testing.T) {
+	servers := newRedirectServers(t)
+	screenshot.SetThumbnailURL(t, servers.origin+"/vi/%s/%s.jpg")
+
+	config := screenshot.NewDefaultConfig()
+	config.Sites = servers.rules
+	dest := filepath.Join(t.TempDir(), "day-1-screenshot.png")
+
+	require.NoError(t, screenshot.YouTubeThumbnailCapturer{}.Capture(context.Background(), "https://www.youtube.com/watch?v=dQw4w9WgXcQ", dest, config))
+	servers.assertNoTokenForwarded(t)
+}
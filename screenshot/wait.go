@@ -0,0 +1,139 @@
+package screenshot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// Wait step kinds.
+const (
+	WaitSelector = "selector"
+	WaitJS       = "js"
+	WaitIdle     = "idle"
+	WaitStable   = "stable"
+	WaitDelay    = "delay"
+)
+
+// WaitStep is one step of the wait between a page loading and the
+// screenshot: for an element matching a selector to be visible, for a JS
+// expression to be truthy, for the network to be idle or the page to be
+// stable for a window, or a fixed delay.
+type WaitStep struct {
+	Kind     string        `json:"kind"`
+	Value    string        `json:"value,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// DefaultWaitSteps are used when a capture configures none.
+var DefaultWaitSteps = []WaitStep{
+	{Kind: WaitStable, Duration: time.Second},
+	{Kind: WaitDelay, Duration: 2 * time.Second},
+}
+
+// ParseWaitStep parses "kind:value", e.g. "selector:#app",
+// "js:window.appReady", "idle:500ms", "stable:1s" or "delay:2s".
+func ParseWaitStep(spec string) (WaitStep, error) {
+	kind, value, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	step := WaitStep{Kind: strings.ToLower(kind)}
+	value = strings.TrimSpace(value)
+
+	switch step.Kind {
+	case WaitSelector, WaitJS:
+		if value == "" {
+			return step, fmt.Errorf("wait step %q needs a %s", spec, step.Kind)
+		}
+		step.Value = value
+	case WaitIdle, WaitStable, WaitDelay:
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return step, fmt.Errorf("wait step %q needs a positive duration, e.g. %s:2s", spec, step.Kind)
+		}
+		step.Duration = duration
+	default:
+		return step, fmt.Errorf("unknown wait step %q, use selector, js, idle, stable or delay", spec)
+	}
+
+	return step, nil
+}
+
+// ParseWaitSteps parses specs with ParseWaitStep. A single "none" returns
+// an empty, non-nil list so that nothing but the page load is waited for.
+func ParseWaitSteps(specs []string) ([]WaitStep, error) {
+	if len(specs) == 1 && strings.TrimSpace(specs[0]) == "none" {
+		return []WaitStep{}, nil
+	}
+
+	steps := make([]WaitStep, 0, len(specs))
+	for _, spec := range specs {
+		step, err := ParseWaitStep(spec)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func (s WaitStep) String() string {
+	if s.Value != "" {
+		return s.Kind + " " + s.Value
+	}
+	return s.Kind + " " + s.Duration.String()
+}
+
+// WaitForPage waits for page to load and its fonts to be ready, then runs
+// steps in order.
+func WaitForPage(page *rod.Page, steps []WaitStep) error {
+	if err := page.WaitLoad(); err != nil {
+		return fmt.Errorf("failed waiting for DOM load: %w", err)
+	}
+
+	_, err := page.Eval(`() => document.fonts.ready`)
+	if err != nil {
+		return fmt.Errorf("failed waiting for fonts: %w", err)
+	}
+
+	for _, step := range steps {
+		if err := step.wait(page); err != nil {
+			return fmt.Errorf("failed waiting for %s: %w", step, err)
+		}
+	}
+
+	return nil
+}
+
+func (s WaitStep) wait(page *rod.Page) error {
+	switch s.Kind {
+	case WaitSelector:
+		element, err := page.Element(s.Value)
+		if err != nil {
+			return err
+		}
+		return element.WaitVisible()
+	case WaitJS:
+		return page.Wait(rod.Eval(`() => (` + s.Value + `)`).ByPromise())
+	case WaitIdle:
+		// Connections that stay open would never let the network go idle
+		page.WaitRequestIdle(s.Duration, nil, nil, []proto.NetworkResourceType{
+			proto.NetworkResourceTypeWebSocket,
+			proto.NetworkResourceTypeEventSource,
+		})()
+		return page.GetContext().Err()
+	case WaitStable:
+		return page.WaitStable(s.Duration)
+	case WaitDelay:
+		ctx := page.GetContext()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.Duration):
+			return nil
+		}
+	default:
+		return fmt.Errorf("unknown wait step %q", s.Kind)
+	}
+}
@@ -0,0 +1,60 @@
+package screenshot_test
+
+import (
+	"testing"
+	"time"
+
+	"screenshot-tweets/screenshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWaitStep(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		spec     string
+		expected screenshot.WaitStep
+		err      string
+	}{
+		{"selector", "selector:#app .loaded", screenshot.WaitStep{Kind: screenshot.WaitSelector, Value: "#app .loaded"}, ""},
+		{"js with colons", "js:document.title === 'a:b'", screenshot.WaitStep{Kind: screenshot.WaitJS, Value: "document.title === 'a:b'"}, ""},
+		{"idle", "idle:750ms", screenshot.WaitStep{Kind: screenshot.WaitIdle, Duration: 750 * time.Millisecond}, ""},
+		{"stable", "Stable: 2s", screenshot.WaitStep{Kind: screenshot.WaitStable, Duration: 2 * time.Second}, ""},
+		{"delay", "delay:3s", screenshot.WaitStep{Kind: screenshot.WaitDelay, Duration: 3 * time.Second}, ""},
+		{"empty selector", "selector:", screenshot.WaitStep{}, `wait step "selector:" needs a selector`},
+		{"missing duration", "delay", screenshot.WaitStep{}, `wait step "delay" needs a positive duration, e.g. delay:2s`},
+		{"negative duration", "idle:-1s", screenshot.WaitStep{}, `wait step "idle:-1s" needs a positive duration, e.g. idle:2s`},
+		{"unknown kind", "sleep:2s", screenshot.WaitStep{}, `unknown wait step "sleep:2s", use selector, js, idle, stable or delay`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			step, err := screenshot.ParseWaitStep(test.spec)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, step)
+		})
+	}
+}
+
+func TestParseWaitSteps(t *testing.T) {
+	steps, err := screenshot.ParseWaitSteps([]string{"none"})
+	require.NoError(t, err)
+	assert.NotNil(t, steps)
+	assert.Empty(t, steps)
+
+	steps, err = screenshot.ParseWaitSteps([]string{"selector:main", "delay:1s"})
+	require.NoError(t, err)
+	assert.Equal(t, "selector main", steps[0].String())
+	assert.Equal(t, "delay 1s", steps[1].String())
+
+	_, err = screenshot.ParseWaitSteps([]string{"selector:main", "none"})
+	assert.EqualError(t, err, `unknown wait step "none", use selector, js, idle, stable or delay`)
+
+	assert.Equal(t, []string{"stable 1s", "delay 2s"}, []string{
+		screenshot.DefaultWaitSteps[0].String(),
+		screenshot.DefaultWaitSteps[1].String(),
+	})
+}